@@ -0,0 +1,707 @@
+// Package ethtest provides an in-memory Ethereum backend for unit testing the yield farming client
+package ethtest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Backend is a fake Ethereum RPC backend. Zero values are usable; exported fields
+// configure canned responses and record what the client sent.
+type Backend struct {
+	mu sync.Mutex
+
+	ChainIDValue *big.Int
+	GasPrice     *big.Int
+	GasLimit     uint64
+	Head         *types.Header
+
+	// Balance is the ether balance of accounts without one set by SetBalance
+	Balance *big.Int
+
+	// AutoMine makes every sent transaction immediately produce a successful receipt
+	AutoMine bool
+
+	// Err, when set, is returned from every RPC method
+	Err error
+
+	nonces         map[common.Address]uint64
+	balances       map[common.Address]*big.Int
+	code           map[common.Address][]byte
+	receipts       map[common.Hash]*types.Receipt
+	callResults    map[string][]byte
+	addrResults    map[common.Address]map[string][]byte
+	argResults     map[string][]byte
+	blockResults   map[uint64]map[string][]byte
+	headers        map[uint64]*types.Header
+	callErrors     map[string]error
+	estimateErr    error
+	sendErr        error
+	sendErrAccepts bool
+	estimateBlocks []rpc.BlockNumber
+
+	sent        []*types.Transaction
+	calls       []ethereum.CallMsg
+	logs        []types.Log
+	logQueries  []ethereum.FilterQuery
+	methodCalls map[string]int
+
+	subscriptions []*logSubscription
+}
+
+// NewBackend creates a fake mainnet backend with a 1 gwei gas price, a 21000 gas estimate,
+// a head at block 1 and 100 ether in every account
+func NewBackend() *Backend {
+	return &Backend{
+		ChainIDValue: big.NewInt(1),
+		GasPrice:     big.NewInt(1000000000),
+		GasLimit:     21000,
+		Head:         &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(0)},
+		Balance:      new(big.Int).Mul(big.NewInt(100), big.NewInt(1e18)),
+	}
+}
+
+// SetGasPrice changes the suggested gas price; unlike assigning GasPrice it is safe while
+// the client is running
+func (b *Backend) SetGasPrice(price *big.Int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.GasPrice = price
+}
+
+// SetNonce sets the pending nonce reported for account
+func (b *Backend) SetNonce(account common.Address, nonce uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.nonces == nil {
+		b.nonces = make(map[common.Address]uint64)
+	}
+	b.nonces[account] = nonce
+}
+
+// SetBalance sets the ether balance reported for account
+func (b *Backend) SetBalance(account common.Address, balance *big.Int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.balances == nil {
+		b.balances = make(map[common.Address]*big.Int)
+	}
+	b.balances[account] = new(big.Int).Set(balance)
+}
+
+// SetCode sets the bytecode reported for account
+func (b *Backend) SetCode(account common.Address, code []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.code == nil {
+		b.code = make(map[common.Address][]byte)
+	}
+	b.code[account] = code
+}
+
+// SetReceipt registers the receipt returned for txHash
+func (b *Backend) SetReceipt(txHash common.Hash, receipt *types.Receipt) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.receipts == nil {
+		b.receipts = make(map[common.Hash]*types.Receipt)
+	}
+	b.receipts[txHash] = receipt
+}
+
+// SetCallResult registers the ABI-encoded return values for calls to method
+func (b *Backend) SetCallResult(method abi.Method, values ...interface{}) error {
+	output, err := method.Outputs.Pack(values...)
+	if err != nil {
+		return fmt.Errorf("failed to pack %s outputs: %w", method.Name, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.callResults == nil {
+		b.callResults = make(map[string][]byte)
+	}
+	b.callResults[string(method.ID)] = output
+	return nil
+}
+
+// SetCallResultAt registers return values for calls to method on one contract address,
+// taking precedence over results registered with SetCallResult
+func (b *Backend) SetCallResultAt(address common.Address, method abi.Method, values ...interface{}) error {
+	output, err := method.Outputs.Pack(values...)
+	if err != nil {
+		return fmt.Errorf("failed to pack %s outputs: %w", method.Name, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.addrResults == nil {
+		b.addrResults = make(map[common.Address]map[string][]byte)
+	}
+	if b.addrResults[address] == nil {
+		b.addrResults[address] = make(map[string][]byte)
+	}
+	b.addrResults[address][string(method.ID)] = output
+	return nil
+}
+
+// SetCallResultFor registers return values for calls to method with exactly args, taking
+// precedence over results registered for the method as a whole
+func (b *Backend) SetCallResultFor(method abi.Method, args []interface{}, values ...interface{}) error {
+	input, err := method.Inputs.Pack(args...)
+	if err != nil {
+		return fmt.Errorf("failed to pack %s inputs: %w", method.Name, err)
+	}
+	output, err := method.Outputs.Pack(values...)
+	if err != nil {
+		return fmt.Errorf("failed to pack %s outputs: %w", method.Name, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.argResults == nil {
+		b.argResults = make(map[string][]byte)
+	}
+	b.argResults[string(method.ID)+string(input)] = output
+	return nil
+}
+
+// SetCallResultAtBlock registers return values for calls to method made against the state
+// at block number, taking precedence over results registered without a block
+func (b *Backend) SetCallResultAtBlock(number uint64, method abi.Method, values ...interface{}) error {
+	output, err := method.Outputs.Pack(values...)
+	if err != nil {
+		return fmt.Errorf("failed to pack %s outputs: %w", method.Name, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.blockResults == nil {
+		b.blockResults = make(map[uint64]map[string][]byte)
+	}
+	if b.blockResults[number] == nil {
+		b.blockResults[number] = make(map[string][]byte)
+	}
+	b.blockResults[number][string(method.ID)] = output
+	return nil
+}
+
+// AddLog appends an event log returned by matching FilterLogs queries
+func (b *Backend) AddLog(log types.Log) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.logs = append(b.logs, log)
+}
+
+// LogQueries returns the FilterLogs queries made through the backend, in order
+func (b *Backend) LogQueries() []ethereum.FilterQuery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]ethereum.FilterQuery(nil), b.logQueries...)
+}
+
+// SetCallError makes calls to method fail with err
+func (b *Backend) SetCallError(method abi.Method, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.callErrors == nil {
+		b.callErrors = make(map[string]error)
+	}
+	b.callErrors[string(method.ID)] = err
+}
+
+// SetEstimateGasError makes EstimateGas fail with err, e.g. an RPC error carrying revert data
+func (b *Backend) SetEstimateGasError(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.estimateErr = err
+}
+
+// SetSendError makes the next SendTransaction return err. With accepted set the
+// transaction is still recorded, like a send that timed out after the node received it.
+func (b *Backend) SetSendError(err error, accepted bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sendErr = err
+	b.sendErrAccepts = accepted
+}
+
+// SentTransactions returns the transactions broadcast through the backend, in order
+func (b *Backend) SentTransactions() []*types.Transaction {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]*types.Transaction(nil), b.sent...)
+}
+
+// Calls returns the read-only calls made through the backend, in order
+func (b *Backend) Calls() []ethereum.CallMsg {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]ethereum.CallMsg(nil), b.calls...)
+}
+
+// MethodCalls returns how many times the named RPC method has been invoked
+func (b *Backend) MethodCalls(method string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.methodCalls[method]
+}
+
+// record counts an invocation of method; callers must hold b.mu
+func (b *Backend) record(method string) {
+	if b.methodCalls == nil {
+		b.methodCalls = make(map[string]int)
+	}
+	b.methodCalls[method]++
+}
+
+// ChainID returns the configured chain ID
+func (b *Backend) ChainID(ctx context.Context) (*big.Int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	return new(big.Int).Set(b.ChainIDValue), nil
+}
+
+// SuggestGasPrice returns the configured gas price
+func (b *Backend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.record("SuggestGasPrice")
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	return new(big.Int).Set(b.GasPrice), nil
+}
+
+// PendingNonceAt returns the next nonce for account, advancing as transactions are sent
+func (b *Backend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Err != nil {
+		return 0, b.Err
+	}
+	return b.nonces[account], nil
+}
+
+// EstimateGas returns the configured gas limit
+func (b *Backend) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.estimateGas()
+}
+
+// EstimateGasAtBlock returns the configured gas limit, recording the requested block state
+func (b *Backend) EstimateGasAtBlock(ctx context.Context, msg ethereum.CallMsg, block rpc.BlockNumber) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.estimateBlocks = append(b.estimateBlocks, block)
+	return b.estimateGas()
+}
+
+// EstimateBlocks returns the block states passed to EstimateGasAtBlock, in order
+func (b *Backend) EstimateBlocks() []rpc.BlockNumber {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]rpc.BlockNumber(nil), b.estimateBlocks...)
+}
+
+// estimateGas records an estimate and returns the configured limit or error. The caller
+// must hold mu.
+func (b *Backend) estimateGas() (uint64, error) {
+	b.record("EstimateGas")
+	if b.Err != nil {
+		return 0, b.Err
+	}
+	if b.estimateErr != nil {
+		return 0, b.estimateErr
+	}
+	return b.GasLimit, nil
+}
+
+// SendTransaction records tx and advances the sender's nonce
+func (b *Backend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.record("SendTransaction")
+	if b.Err != nil {
+		return b.Err
+	}
+	sendErr := b.sendErr
+	b.sendErr = nil
+	if sendErr != nil && !b.sendErrAccepts {
+		return sendErr
+	}
+
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return fmt.Errorf("failed to recover sender: %w", err)
+	}
+
+	if b.nonces == nil {
+		b.nonces = make(map[common.Address]uint64)
+	}
+	b.nonces[sender] = tx.Nonce() + 1
+	b.sent = append(b.sent, tx)
+
+	if b.AutoMine {
+		if b.receipts == nil {
+			b.receipts = make(map[common.Hash]*types.Receipt)
+		}
+		b.receipts[tx.Hash()] = &types.Receipt{
+			Status:            types.ReceiptStatusSuccessful,
+			TxHash:            tx.Hash(),
+			GasUsed:           tx.Gas(),
+			EffectiveGasPrice: effectiveGasPrice(tx, b.Head.BaseFee),
+			BlockNumber:       new(big.Int).Set(b.Head.Number),
+		}
+	}
+	return sendErr
+}
+
+// effectiveGasPrice is the price per gas tx pays in a block with baseFee: its gas price, or
+// for dynamic-fee transactions the base fee plus as much of the tip as the fee cap allows
+func effectiveGasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	if baseFee == nil || tx.Type() != types.DynamicFeeTxType {
+		return tx.GasPrice()
+	}
+	tip := tx.EffectiveGasTipValue(baseFee)
+	return tip.Add(tip, baseFee)
+}
+
+// TransactionByHash returns a sent transaction, pending until it has a receipt, or
+// ethereum.NotFound
+func (b *Backend) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.record("TransactionByHash")
+	if b.Err != nil {
+		return nil, false, b.Err
+	}
+	for _, tx := range b.sent {
+		if tx.Hash() == hash {
+			_, mined := b.receipts[hash]
+			return tx, !mined, nil
+		}
+	}
+	return nil, false, ethereum.NotFound
+}
+
+// CallContract returns the result registered for the called method selector
+func (b *Backend) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.record("CallContract")
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	b.calls = append(b.calls, msg)
+
+	if len(msg.Data) < 4 {
+		return nil, fmt.Errorf("call data too short: %d bytes", len(msg.Data))
+	}
+	selector := string(msg.Data[:4])
+	if err, ok := b.callErrors[selector]; ok {
+		return nil, err
+	}
+	if blockNumber != nil && blockNumber.IsUint64() {
+		if output, ok := b.blockResults[blockNumber.Uint64()][selector]; ok {
+			return output, nil
+		}
+	}
+	if output, ok := b.argResults[string(msg.Data)]; ok {
+		return output, nil
+	}
+	if msg.To != nil {
+		if output, ok := b.addrResults[*msg.To][selector]; ok {
+			return output, nil
+		}
+	}
+	output, ok := b.callResults[selector]
+	if !ok {
+		return nil, fmt.Errorf("no result registered for selector %x", msg.Data[:4])
+	}
+	return output, nil
+}
+
+// CodeAt returns the bytecode registered for account
+func (b *Backend) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	return b.code[account], nil
+}
+
+// BalanceAt returns the balance set for account, or Balance
+func (b *Backend) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.record("BalanceAt")
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	balance, ok := b.balances[account]
+	if !ok {
+		balance = b.Balance
+	}
+	if balance == nil {
+		return new(big.Int), nil
+	}
+	return new(big.Int).Set(balance), nil
+}
+
+// TransactionReceipt returns the registered receipt, or ethereum.NotFound if none exists yet
+func (b *Backend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.record("TransactionReceipt")
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	receipt, ok := b.receipts[txHash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
+
+// BlockByNumber returns a block built from the configured head header
+func (b *Backend) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.record("BlockByNumber")
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	return types.NewBlockWithHeader(b.Head), nil
+}
+
+// BlockNumber returns the configured head's number
+func (b *Backend) BlockNumber(ctx context.Context) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.record("BlockNumber")
+	if b.Err != nil {
+		return 0, b.Err
+	}
+	return b.Head.Number.Uint64(), nil
+}
+
+// SetBlockNumber moves the head to number; unlike assigning Head it is safe while the
+// client is running
+func (b *Backend) SetBlockNumber(number uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	head := types.CopyHeader(b.Head)
+	head.Number = new(big.Int).SetUint64(number)
+	b.Head = head
+}
+
+// SetHeader makes HeaderByNumber return header for its block number
+func (b *Backend) SetHeader(header *types.Header) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.headers == nil {
+		b.headers = make(map[uint64]*types.Header)
+	}
+	b.headers[header.Number.Uint64()] = types.CopyHeader(header)
+}
+
+// HeaderByNumber returns a copy of the header set for number, or of the configured head
+func (b *Backend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.record("HeaderByNumber")
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	if number != nil {
+		if header, ok := b.headers[number.Uint64()]; ok {
+			return types.CopyHeader(header), nil
+		}
+	}
+	return types.CopyHeader(b.Head), nil
+}
+
+// FilterLogs returns the added logs matching the query's block range, addresses and topics
+func (b *Backend) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.record("FilterLogs")
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	b.logQueries = append(b.logQueries, q)
+
+	var matched []types.Log
+	for _, log := range b.logs {
+		if q.FromBlock != nil && log.BlockNumber < q.FromBlock.Uint64() {
+			continue
+		}
+		if q.ToBlock != nil && log.BlockNumber > q.ToBlock.Uint64() {
+			continue
+		}
+		if len(q.Addresses) > 0 && !containsAddress(q.Addresses, log.Address) {
+			continue
+		}
+		if !matchesTopics(q.Topics, log.Topics) {
+			continue
+		}
+		matched = append(matched, log)
+	}
+	return matched, nil
+}
+
+func containsAddress(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+// logSubscription is a SubscribeFilterLogs subscription fed by EmitLog
+type logSubscription struct {
+	query ethereum.FilterQuery
+	logs  chan<- types.Log
+	err   chan error
+	once  sync.Once
+	done  chan struct{}
+}
+
+// Unsubscribe stops delivery and closes the error channel
+func (s *logSubscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		close(s.err)
+	})
+}
+
+// Err returns the channel receiving the subscription's failure
+func (s *logSubscription) Err() <-chan error {
+	return s.err
+}
+
+// SubscribeFilterLogs delivers logs passed to EmitLog that match the query's addresses
+// and topics
+func (b *Backend) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.record("SubscribeFilterLogs")
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	sub := &logSubscription{query: q, logs: ch, err: make(chan error, 1), done: make(chan struct{})}
+	b.subscriptions = append(b.subscriptions, sub)
+	return sub, nil
+}
+
+// EmitLog adds log as AddLog does and delivers it to every matching live subscription,
+// blocking until each has received it
+func (b *Backend) EmitLog(log types.Log) {
+	b.mu.Lock()
+	b.logs = append(b.logs, log)
+	subscriptions := append([]*logSubscription(nil), b.subscriptions...)
+	b.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		if len(sub.query.Addresses) > 0 && !containsAddress(sub.query.Addresses, log.Address) {
+			continue
+		}
+		if !matchesTopics(sub.query.Topics, log.Topics) {
+			continue
+		}
+		select {
+		case sub.logs <- log:
+		case <-sub.done:
+		}
+	}
+}
+
+// FailSubscriptions ends every live subscription with err, as a dropped connection would
+func (b *Backend) FailSubscriptions(err error) {
+	b.mu.Lock()
+	subscriptions := b.subscriptions
+	b.subscriptions = nil
+	b.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		sub.once.Do(func() {
+			sub.err <- err
+			close(sub.done)
+		})
+	}
+}
+
+// matchesTopics applies eth_getLogs topic semantics: each position matches any of its
+// hashes, and an empty position matches anything
+func matchesTopics(filter [][]common.Hash, topics []common.Hash) bool {
+	if len(filter) > len(topics) {
+		return false
+	}
+	for i, options := range filter {
+		if len(options) == 0 {
+			continue
+		}
+		found := false
+		for _, option := range options {
+			if option == topics[i] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
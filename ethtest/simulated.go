@@ -0,0 +1,214 @@
+package ethtest
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/asm"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SimulatedChainID is the chain ID the simulated backend signs and validates against
+const SimulatedChainID = 1337
+
+// simulatedGasLimit is the block gas limit of the simulated chain
+const simulatedGasLimit = 30_000_000
+
+// Simulated is an in-process blockchain running real EVM execution. It mines a block after
+// every sent transaction, so receipts are available as soon as SendTransaction returns.
+type Simulated struct {
+	*backends.SimulatedBackend
+}
+
+// NewSimulated starts a simulated chain whose genesis funds the accounts in alloc
+func NewSimulated(alloc core.GenesisAlloc) *Simulated {
+	return &Simulated{SimulatedBackend: backends.NewSimulatedBackend(alloc, simulatedGasLimit)}
+}
+
+// ChainID returns SimulatedChainID
+func (s *Simulated) ChainID(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(SimulatedChainID), nil
+}
+
+// BlockNumber returns the number of the latest mined block
+func (s *Simulated) BlockNumber(ctx context.Context) (uint64, error) {
+	return s.Blockchain().CurrentBlock().Number.Uint64(), nil
+}
+
+// SendTransaction adds tx to the pending block and mines it
+func (s *Simulated) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := s.SimulatedBackend.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	s.Commit()
+	return nil
+}
+
+// TestPoolABI is the interface of the pool deployed by DeployTestPool. Deposits and
+// withdrawals only update the caller's recorded stake; no tokens move. Pending rewards
+// are a flat 1% of the stake.
+const TestPoolABI = `[
+	{"type":"function","name":"deposit","stateMutability":"nonpayable","inputs":[{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"withdraw","stateMutability":"nonpayable","inputs":[{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"claimRewards","stateMutability":"nonpayable","inputs":[],"outputs":[]},
+	{"type":"function","name":"userInfo","stateMutability":"view","inputs":[{"name":"user","type":"address"}],"outputs":[{"name":"amount","type":"uint256"},{"name":"rewardDebt","type":"uint256"}]},
+	{"type":"function","name":"pendingRewards","stateMutability":"view","inputs":[{"name":"user","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"event","name":"Deposit","inputs":[{"name":"user","type":"address","indexed":true},{"name":"amount","type":"uint256","indexed":false}]},
+	{"type":"event","name":"Withdraw","inputs":[{"name":"user","type":"address","indexed":true},{"name":"amount","type":"uint256","indexed":false}]}
+]`
+
+// testPoolAsm is the runtime code of the test pool, in go-ethereum's core/asm syntax. Each
+// user's stake is stored in the slot keyed by their address.
+const testPoolAsm = `
+	PUSH 0
+	CALLDATALOAD
+	PUSH 0xe0
+	SHR
+	DUP1
+	PUSH 0xb6b55f25 ;; deposit(uint256)
+	EQ
+	JUMPI @deposit
+	DUP1
+	PUSH 0x2e1a7d4d ;; withdraw(uint256)
+	EQ
+	JUMPI @withdraw
+	DUP1
+	PUSH 0x372500ab ;; claimRewards()
+	EQ
+	JUMPI @claim
+	DUP1
+	PUSH 0x1959a002 ;; userInfo(address)
+	EQ
+	JUMPI @userInfo
+	DUP1
+	PUSH 0x31d7a262 ;; pendingRewards(address)
+	EQ
+	JUMPI @pending
+fail:
+	PUSH 0
+	DUP1
+	REVERT
+
+deposit: ;; stake[caller] += amount; emit Deposit(caller, amount)
+	PUSH 4
+	CALLDATALOAD
+	DUP1
+	CALLER
+	SLOAD
+	ADD
+	CALLER
+	SSTORE
+	PUSH 0
+	MSTORE
+	CALLER
+	PUSH 0xe1fffcc4923d04b559f4d29a8bfc6cda04eb5b0d3c460751c2402c5c5cc9109c
+	PUSH 0x20
+	PUSH 0
+	LOG2
+	STOP
+
+withdraw: ;; require(stake[caller] >= amount); stake[caller] -= amount; emit Withdraw(caller, amount)
+	PUSH 4
+	CALLDATALOAD
+	DUP1
+	CALLER
+	SLOAD
+	LT
+	JUMPI @fail
+	DUP1
+	CALLER
+	SLOAD
+	SUB
+	CALLER
+	SSTORE
+	PUSH 0
+	MSTORE
+	CALLER
+	PUSH 0x884edad9ce6fa2440d8a54cc123490eb96d2768479d49ff9c7366125a9424364
+	PUSH 0x20
+	PUSH 0
+	LOG2
+	STOP
+
+claim:
+	STOP
+
+userInfo: ;; return (stake[user], 0)
+	PUSH 4
+	CALLDATALOAD
+	SLOAD
+	PUSH 0
+	MSTORE
+	PUSH 0x40
+	PUSH 0
+	RETURN
+
+pending: ;; return stake[user] / 100
+	PUSH 100
+	PUSH 4
+	CALLDATALOAD
+	SLOAD
+	DIV
+	PUSH 0
+	MSTORE
+	PUSH 0x20
+	PUSH 0
+	RETURN
+`
+
+// TestPoolCode returns the deployment bytecode of the test pool: a constructor that copies
+// the assembled runtime code out of the init code and returns it
+func TestPoolCode() ([]byte, error) {
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex([]byte(testPoolAsm), false))
+	runtimeHex, errs := compiler.Compile()
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to assemble test pool: %v", errs)
+	}
+	runtime := common.FromHex(runtimeHex)
+
+	// PUSH2 len, DUP1, PUSH1 offset, PUSH1 0, CODECOPY, PUSH1 0, RETURN
+	constructor := []byte{0x61, 0, 0, 0x80, 0x60, 12, 0x60, 0, 0x39, 0x60, 0, 0xf3}
+	binary.BigEndian.PutUint16(constructor[1:3], uint16(len(runtime)))
+	return append(constructor, runtime...), nil
+}
+
+// DeployTestPool deploys the test pool from auth's account and returns its address
+func (s *Simulated) DeployTestPool(ctx context.Context, auth *bind.TransactOpts) (common.Address, error) {
+	code, err := TestPoolCode()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	nonce, err := s.PendingNonceAt(ctx, auth.From)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to get nonce: %w", err)
+	}
+	gasPrice, err := s.SuggestGasPrice(ctx)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to get gas price: %w", err)
+	}
+
+	tx, err := auth.Signer(auth.From, types.NewContractCreation(nonce, big.NewInt(0), 1_000_000, gasPrice, code))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to sign deployment: %w", err)
+	}
+	if err := s.SendTransaction(ctx, tx); err != nil {
+		return common.Address{}, fmt.Errorf("failed to send deployment: %w", err)
+	}
+
+	receipt, err := s.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to get deployment receipt: %w", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return common.Address{}, fmt.Errorf("test pool deployment failed in tx %s", tx.Hash().Hex())
+	}
+	return receipt.ContractAddress, nil
+}
@@ -0,0 +1,7181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"blockchain-yield-farming/ethtest"
+)
+
+// testPoolABI describes the pool contract methods exercised by the tests
+const testPoolABI = `
+	{"type":"function","name":"deposit","inputs":[{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"deposit","inputs":[{"name":"amount","type":"uint256"},{"name":"minSharesOut","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"withdraw","inputs":[{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"claimRewards","inputs":[],"outputs":[]},
+	{"type":"function","name":"previewDeposit","stateMutability":"view","inputs":[{"name":"assets","type":"uint256"}],"outputs":[{"name":"shares","type":"uint256"}]}`
+
+var testContractAddress = common.HexToAddress("0x1234567890123456789012345678901234567890")
+
+// testPoolCode stands in for the pool's deployed bytecode
+var testPoolCode = []byte{0x60, 0x80, 0x60, 0x40}
+
+// newTestClient returns a client wired to a fake backend and the test pool ABI,
+// extended with any additional ABI entries
+func newTestClient(t *testing.T, extraABI ...string) (*YieldFarmingClient, *ethtest.Backend) {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	backend := ethtest.NewBackend()
+	backend.SetCode(testContractAddress, testPoolCode)
+	client, err := NewYieldFarmingClientWithBackend(backend, testContractAddress, common.Bytes2Hex(crypto.FromECDSA(key)))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	entries := append([]string{testPoolABI}, extraABI...)
+	client.contractABI, err = abi.JSON(strings.NewReader("[" + strings.Join(entries, ",") + "]"))
+	if err != nil {
+		t.Fatalf("failed to parse test ABI: %v", err)
+	}
+	return client, backend
+}
+
+// testStakingToken is the staking token setPoolTVL configures on clients without one
+var testStakingToken = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+// setPoolTVL registers tvl as the pool's balance of its staking token, configuring
+// testStakingToken when the client has no staking token set
+func setPoolTVL(t *testing.T, client *YieldFarmingClient, backend *ethtest.Backend, tvl *big.Int) {
+	t.Helper()
+
+	if client.stakingToken == (common.Address{}) {
+		WithStakingToken(testStakingToken)(client)
+	}
+	if err := backend.SetCallResultFor(erc20ABI.Methods["balanceOf"], []interface{}{testContractAddress}, tvl); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDepositSendsSignedTransaction(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.SetNonce(client.auth.From, 7)
+
+	tx, err := client.Deposit(context.Background(), big.NewInt(1000))
+	if err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+
+	sent := backend.SentTransactions()
+	if len(sent) != 1 || sent[0].Hash() != tx.Hash() {
+		t.Fatalf("expected the deposit to be broadcast once, got %d transactions", len(sent))
+	}
+	if tx.Nonce() != 7 {
+		t.Errorf("expected nonce 7, got %d", tx.Nonce())
+	}
+	if *tx.To() != testContractAddress {
+		t.Errorf("expected recipient %s, got %s", testContractAddress.Hex(), tx.To().Hex())
+	}
+
+	sender, err := types.Sender(types.NewEIP155Signer(big.NewInt(1)), tx)
+	if err != nil {
+		t.Fatalf("failed to recover sender: %v", err)
+	}
+	if sender != client.auth.From {
+		t.Errorf("expected sender %s, got %s", client.auth.From.Hex(), sender.Hex())
+	}
+}
+
+func TestBuildTxReturnsUnsignedTransaction(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.SetNonce(client.auth.From, 4)
+	ctx := context.Background()
+	amount := big.NewInt(1000)
+
+	tests := []struct {
+		name   string
+		build  func() (*types.Transaction, error)
+		method string
+		args   []interface{}
+	}{
+		{name: "deposit", build: func() (*types.Transaction, error) { return client.BuildDepositTx(ctx, amount) }, method: "deposit", args: []interface{}{amount}},
+		{name: "withdraw", build: func() (*types.Transaction, error) { return client.BuildWithdrawTx(ctx, amount) }, method: "withdraw", args: []interface{}{amount}},
+		{name: "claim", build: func() (*types.Transaction, error) { return client.BuildClaimRewardsTx(ctx) }, method: "claimRewards"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := tt.build()
+			if err != nil {
+				t.Fatalf("build failed: %v", err)
+			}
+
+			want, err := client.contractABI.Pack(tt.method, tt.args...)
+			if err != nil {
+				t.Fatalf("failed to pack %s: %v", tt.method, err)
+			}
+			if !bytes.Equal(tx.Data(), want) {
+				t.Errorf("expected %s call data %x, got %x", tt.method, want, tx.Data())
+			}
+			if tx.Nonce() != 4 || *tx.To() != testContractAddress || tx.Value().Sign() != 0 {
+				t.Errorf("unexpected nonce %d, recipient %s or value %s", tx.Nonce(), tx.To().Hex(), tx.Value())
+			}
+			if tx.Gas() != backend.GasLimit || tx.GasPrice().Cmp(backend.GasPrice) != 0 {
+				t.Errorf("expected gas %d at %s, got %d at %s", backend.GasLimit, backend.GasPrice, tx.Gas(), tx.GasPrice())
+			}
+			if v, r, s := tx.RawSignatureValues(); v.Sign() != 0 || r.Sign() != 0 || s.Sign() != 0 {
+				t.Errorf("expected no signature, got v=%s r=%s s=%s", v, r, s)
+			}
+		})
+	}
+
+	if sent := backend.SentTransactions(); len(sent) != 0 {
+		t.Errorf("expected nothing broadcast while building, got %d transactions", len(sent))
+	}
+}
+
+func TestBuildTxRunsSendChecks(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	if _, err := client.BuildDepositTx(context.Background(), big.NewInt(0)); !errors.Is(err, ErrZeroAmount) {
+		t.Errorf("expected ErrZeroAmount, got %v", err)
+	}
+	if _, err := client.BuildWithdrawTx(context.Background(), tokens(11, 18)); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("expected ErrInsufficientBalance, got %v", err)
+	}
+
+	client.SetSigner(nil)
+	if _, err := client.BuildClaimRewardsTx(context.Background()); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly without an account, got %v", err)
+	}
+}
+
+func TestBroadcastExternallySignedTransaction(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	client, backend := newTestClient(t)
+	client.SetSigner(NewPrivateKeySigner(key))
+	store := NewMemoryTxStore()
+	WithTxStore(store)(client)
+	ctx := context.Background()
+
+	tx, err := client.BuildDepositTx(ctx, big.NewInt(1000))
+	if err != nil {
+		t.Fatalf("BuildDepositTx failed: %v", err)
+	}
+	if err := client.Broadcast(ctx, tx); err == nil {
+		t.Fatal("expected an unsigned transaction to be rejected")
+	}
+
+	signed, err := types.SignTx(tx, types.NewEIP155Signer(big.NewInt(1)), key)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if err := client.Broadcast(ctx, signed); err != nil {
+		t.Fatalf("Broadcast failed: %v", err)
+	}
+
+	sent := backend.SentTransactions()
+	if len(sent) != 1 || sent[0].Hash() != signed.Hash() {
+		t.Fatalf("expected the signed deposit to be broadcast once, got %d transactions", len(sent))
+	}
+	records, _ := store.List(TxFilter{Method: "deposit"})
+	if len(records) != 1 || records[0].Hash != signed.Hash() || records[0].Status != TxStatusPending {
+		t.Errorf("expected a pending deposit record, got %+v", records)
+	}
+}
+
+func TestWithGasPriceOverridesSuggestedPrice(t *testing.T) {
+	ctx := context.Background()
+	price := big.NewInt(42e9)
+	client, backend := newTestClient(t)
+
+	for _, send := range []struct {
+		name string
+		send func(...CallOption) (*types.Transaction, error)
+	}{
+		{"deposit", func(opts ...CallOption) (*types.Transaction, error) {
+			return client.Deposit(ctx, big.NewInt(1000), opts...)
+		}},
+		{"withdraw", func(opts ...CallOption) (*types.Transaction, error) {
+			return client.Withdraw(ctx, big.NewInt(1000), opts...)
+		}},
+		{"claim", func(opts ...CallOption) (*types.Transaction, error) {
+			return client.ClaimRewards(ctx, opts...)
+		}},
+	} {
+		tx, err := send.send(WithGasPrice(price))
+		if err != nil {
+			t.Fatalf("%s failed: %v", send.name, err)
+		}
+		if tx.GasPrice().Cmp(price) != 0 {
+			t.Errorf("%s: expected gas price %s, got %s", send.name, price, tx.GasPrice())
+		}
+		if _, err := types.Sender(types.NewEIP155Signer(big.NewInt(1)), tx); err != nil {
+			t.Errorf("%s: expected a signed transaction: %v", send.name, err)
+		}
+	}
+	if calls := backend.MethodCalls("SuggestGasPrice"); calls != 0 {
+		t.Errorf("expected SuggestGasPrice to be bypassed, got %d calls", calls)
+	}
+
+	tx, err := client.Deposit(ctx, big.NewInt(1000))
+	if err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	if tx.GasPrice().Cmp(backend.GasPrice) != 0 {
+		t.Errorf("expected the suggested price without the option, got %s", tx.GasPrice())
+	}
+}
+
+func TestWithGasPriceValidation(t *testing.T) {
+	client, backend := newTestClient(t)
+	for _, price := range []*big.Int{big.NewInt(0), big.NewInt(-1)} {
+		if _, err := client.Deposit(context.Background(), big.NewInt(1000), WithGasPrice(price)); err == nil || !strings.Contains(err.Error(), "must be positive") {
+			t.Errorf("expected gas price %s to be rejected, got %v", price, err)
+		}
+	}
+
+	WithMaxGasPrice(big.NewInt(10e9))(client)
+	if _, err := client.Deposit(context.Background(), big.NewInt(1000), WithGasPrice(big.NewInt(11e9))); !errors.Is(err, ErrGasPriceTooHigh) {
+		t.Errorf("expected ErrGasPriceTooHigh above the maximum, got %v", err)
+	}
+	if sent := backend.SentTransactions(); len(sent) != 0 {
+		t.Errorf("expected nothing sent, got %d transactions", len(sent))
+	}
+}
+
+func TestDynamicFeeTransactionsWithBaseFee(t *testing.T) {
+	ctx := context.Background()
+	client, backend := newTestClient(t)
+	backend.Head.BaseFee = big.NewInt(4e8)
+
+	supported, err := client.SupportsEIP1559(ctx)
+	if err != nil || !supported {
+		t.Fatalf("expected EIP-1559 support with a base fee, got %v, %v", supported, err)
+	}
+	tx, err := client.Deposit(ctx, big.NewInt(1000))
+	if err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	if tx.Type() != types.DynamicFeeTxType {
+		t.Fatalf("expected a dynamic-fee transaction, got type %d", tx.Type())
+	}
+	// The tip is the suggested 1 gwei price less the 0.4 gwei base fee
+	if tx.GasTipCap().Int64() != 6e8 || tx.GasFeeCap().Int64() != 14e8 {
+		t.Errorf("expected tip 6e8 and fee cap 14e8, got %s and %s", tx.GasTipCap(), tx.GasFeeCap())
+	}
+	if _, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), tx); err != nil {
+		t.Errorf("expected a signed transaction: %v", err)
+	}
+
+	if tx, err = client.Deposit(ctx, big.NewInt(1000), WithGasPrice(big.NewInt(42e9))); err != nil {
+		t.Fatalf("Deposit with gas price failed: %v", err)
+	}
+	if tx.Type() != types.LegacyTxType || tx.GasPrice().Int64() != 42e9 {
+		t.Errorf("expected an explicit gas price to send a legacy transaction, got type %d at %s", tx.Type(), tx.GasPrice())
+	}
+}
+
+func TestLegacyTransactionsWithoutBaseFee(t *testing.T) {
+	ctx := context.Background()
+	client, backend := newTestClient(t)
+
+	for i := 0; i < 2; i++ {
+		tx, err := client.Deposit(ctx, big.NewInt(1000))
+		if err != nil {
+			t.Fatalf("Deposit failed: %v", err)
+		}
+		if tx.Type() != types.LegacyTxType || tx.GasPrice().Cmp(backend.GasPrice) != 0 {
+			t.Errorf("expected a legacy transaction at the suggested price, got type %d at %s", tx.Type(), tx.GasPrice())
+		}
+	}
+	if supported, err := client.SupportsEIP1559(ctx); err != nil || supported {
+		t.Errorf("expected no EIP-1559 support without a base fee, got %v, %v", supported, err)
+	}
+	if calls := backend.MethodCalls("HeaderByNumber"); calls != 1 {
+		t.Errorf("expected the missing base fee to be cached after 1 header read, got %d", calls)
+	}
+}
+
+// forkPoolABI names the pool operations as MasterChef-style forks do
+const forkPoolABI = `[
+	{"type":"function","name":"stake","inputs":[{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"unstake","inputs":[{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"harvest","inputs":[],"outputs":[]}]`
+
+func TestMethodNamesRemapPoolCalls(t *testing.T) {
+	client, backend := newTestClient(t)
+	WithMethodNames(MethodNames{Deposit: "stake", Withdraw: "unstake", ClaimRewards: "harvest"})(client)
+	WithGasLimits(map[string]uint64{"harvest": 90000})(client)
+	var err error
+	if client.contractABI, err = abi.JSON(strings.NewReader(forkPoolABI)); err != nil {
+		t.Fatalf("failed to parse fork ABI: %v", err)
+	}
+	ctx := context.Background()
+
+	deposit, err := client.Deposit(ctx, big.NewInt(1e18))
+	if err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	withdraw, err := client.Withdraw(ctx, big.NewInt(1e18))
+	if err != nil {
+		t.Fatalf("Withdraw failed: %v", err)
+	}
+	claim, err := client.ClaimRewards(ctx)
+	if err != nil {
+		t.Fatalf("ClaimRewards failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		tx     *types.Transaction
+		method string
+	}{{deposit, "stake"}, {withdraw, "unstake"}, {claim, "harvest"}} {
+		if id := client.contractABI.Methods[tt.method].ID; !bytes.Equal(tt.tx.Data()[:4], id) {
+			t.Errorf("expected a %s call, got selector %x", tt.method, tt.tx.Data()[:4])
+		}
+	}
+	if claim.Gas() != 90000 {
+		t.Errorf("expected the harvest gas limit 90000, got %d", claim.Gas())
+	}
+	if sent := len(backend.SentTransactions()); sent != 3 {
+		t.Errorf("expected 3 sent transactions, got %d", sent)
+	}
+}
+
+func TestWithMethodNamesKeepsDefaultsForEmptyFields(t *testing.T) {
+	client, _ := newTestClient(t)
+	WithMethodNames(MethodNames{ClaimRewards: "harvest"})(client)
+
+	want := MethodNames{Deposit: "deposit", Withdraw: "withdraw", ClaimRewards: "harvest"}
+	if client.methodNames != want {
+		t.Errorf("expected %+v, got %+v", want, client.methodNames)
+	}
+}
+
+// fakeTicker is a manually driven ticker for the monitor tests
+type fakeTicker struct {
+	ch      chan time.Time
+	stopped chan struct{}
+}
+
+func newFakeTicker() *fakeTicker {
+	return &fakeTicker{ch: make(chan time.Time), stopped: make(chan struct{})}
+}
+
+func (f *fakeTicker) C() <-chan time.Time { return f.ch }
+
+func (f *fakeTicker) Stop() { close(f.stopped) }
+
+func TestStartAPYMonitorEmitsSnapshotPerTick(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.Head.Number = big.NewInt(42)
+
+	ft := newFakeTicker()
+	client.newTicker = func(time.Duration) ticker { return ft }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	snapshots := client.StartAPYMonitor(ctx, time.Millisecond)
+
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 2; i++ {
+		tick := start.Add(time.Duration(i) * time.Minute)
+		ft.ch <- tick
+
+		snapshot := <-snapshots
+		if snapshot.Err != nil {
+			t.Fatalf("snapshot %d: unexpected error: %v", i, snapshot.Err)
+		}
+		if !snapshot.Timestamp.Equal(tick) {
+			t.Errorf("snapshot %d: expected timestamp %s, got %s", i, tick, snapshot.Timestamp)
+		}
+		if snapshot.BlockNumber != 42 {
+			t.Errorf("snapshot %d: expected block 42, got %d", i, snapshot.BlockNumber)
+		}
+		if snapshot.APY == nil || snapshot.TotalValueLocked == nil || snapshot.RewardRate == nil {
+			t.Errorf("snapshot %d: expected pool metrics to be populated: %+v", i, snapshot)
+		}
+	}
+
+	cancel()
+	if _, ok := <-snapshots; ok {
+		t.Fatal("expected the snapshot channel to be closed after cancellation")
+	}
+	<-ft.stopped
+}
+
+func TestStartAPYMonitorReportsSampleErrors(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.Err = errors.New("connection refused")
+
+	ft := newFakeTicker()
+	client.newTicker = func(time.Duration) ticker { return ft }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	snapshots := client.StartAPYMonitor(ctx, time.Second)
+
+	ft.ch <- time.Now()
+	snapshot := <-snapshots
+	if snapshot.Err == nil || !strings.Contains(snapshot.Err.Error(), "connection refused") {
+		t.Fatalf("expected the backend error on the snapshot, got %v", snapshot.Err)
+	}
+}
+
+func TestStartAPYMonitorRejectsNonPositiveInterval(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		snapshots := client.StartAPYMonitor(context.Background(), interval)
+
+		snapshot, ok := <-snapshots
+		if !ok || snapshot.Err == nil {
+			t.Fatalf("interval %s: expected an error snapshot", interval)
+		}
+		if _, ok := <-snapshots; ok {
+			t.Fatalf("interval %s: expected the channel to be closed", interval)
+		}
+	}
+}
+
+func TestApplySlippage(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      *big.Int
+		slippageBps uint64
+		want        *big.Int
+		wantErr     bool
+	}{
+		{name: "zero slippage", amount: big.NewInt(1000), slippageBps: 0, want: big.NewInt(1000)},
+		{name: "half percent", amount: big.NewInt(1000), slippageBps: 50, want: big.NewInt(995)},
+		{name: "rounds down", amount: big.NewInt(999), slippageBps: 50, want: big.NewInt(994)},
+		{name: "full slippage", amount: big.NewInt(1000), slippageBps: 10000, want: big.NewInt(0)},
+		{name: "above maximum", amount: big.NewInt(1000), slippageBps: 10001, wantErr: true},
+		{name: "nil amount", amount: nil, slippageBps: 50, wantErr: true},
+		{name: "negative amount", amount: big.NewInt(-1), slippageBps: 50, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applySlippage(tt.amount, tt.slippageBps)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Cmp(tt.want) != 0 {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDepositWithMinSharesPacksTwoArgumentOverload(t *testing.T) {
+	client, backend := newTestClient(t)
+
+	amount := big.NewInt(1000)
+	minShares := big.NewInt(990)
+	tx, err := client.DepositWithMinShares(context.Background(), amount, minShares)
+	if err != nil {
+		t.Fatalf("DepositWithMinShares failed: %v", err)
+	}
+
+	want := crypto.Keccak256([]byte("deposit(uint256,uint256)"))[:4]
+	want = append(want, common.LeftPadBytes(amount.Bytes(), 32)...)
+	want = append(want, common.LeftPadBytes(minShares.Bytes(), 32)...)
+	if !bytes.Equal(tx.Data(), want) {
+		t.Errorf("unexpected calldata:\n got %x\nwant %x", tx.Data(), want)
+	}
+	if len(backend.SentTransactions()) != 1 {
+		t.Errorf("expected one transaction to be sent")
+	}
+
+	// The single-argument overload must still resolve for plain deposits
+	tx, err = client.Deposit(context.Background(), amount)
+	if err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	if selector := crypto.Keccak256([]byte("deposit(uint256)"))[:4]; !bytes.Equal(tx.Data()[:4], selector) {
+		t.Errorf("expected deposit(uint256) selector %x, got %x", selector, tx.Data()[:4])
+	}
+}
+
+func TestDepositWithSlippageUsesQuote(t *testing.T) {
+	client, backend := newTestClient(t)
+	if err := backend.SetCallResult(client.contractABI.Methods["previewDeposit"], big.NewInt(2000)); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := client.DepositWithSlippage(context.Background(), big.NewInt(1000), 100)
+	if err != nil {
+		t.Fatalf("DepositWithSlippage failed: %v", err)
+	}
+
+	args, err := client.contractABI.Methods["deposit0"].Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		t.Fatalf("failed to decode calldata: %v", err)
+	}
+	if minShares := args[1].(*big.Int); minShares.Cmp(big.NewInt(1980)) != 0 {
+		t.Errorf("expected min shares 1980, got %s", minShares)
+	}
+
+	// Call options reach the deposit, but the explicit slippage wins over WithSlippage
+	tx, err = client.DepositWithSlippage(context.Background(), big.NewInt(1000), 100, WithGasPrice(big.NewInt(42e9)), WithSlippage(5000))
+	if err != nil {
+		t.Fatalf("DepositWithSlippage with options failed: %v", err)
+	}
+	if tx.GasPrice().Int64() != 42e9 {
+		t.Errorf("expected gas price 42 gwei, got %s", tx.GasPrice())
+	}
+	if args, err = client.contractABI.Methods["deposit0"].Inputs.Unpack(tx.Data()[4:]); err != nil {
+		t.Fatalf("failed to decode calldata: %v", err)
+	}
+	if minShares := args[1].(*big.Int); minShares.Cmp(big.NewInt(1980)) != 0 {
+		t.Errorf("expected min shares 1980, got %s", minShares)
+	}
+}
+
+func TestDepositWithSharesAppliesDefaultSlippage(t *testing.T) {
+	tests := []struct {
+		name          string
+		clientOpts    []ClientOption
+		callOpts      []CallOption
+		wantMinShares int64
+	}{
+		{name: "built-in default", wantMinShares: 1990},
+		{name: "client default", clientOpts: []ClientOption{WithDefaultSlippageBps(200)}, wantMinShares: 1960},
+		{name: "per-call override", clientOpts: []ClientOption{WithDefaultSlippageBps(200)}, callOpts: []CallOption{WithSlippage(100)}, wantMinShares: 1980},
+		{name: "zero slippage", callOpts: []CallOption{WithSlippage(0)}, wantMinShares: 2000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t)
+			for _, opt := range tt.clientOpts {
+				opt(client)
+			}
+			if err := backend.SetCallResult(client.contractABI.Methods["previewDeposit"], big.NewInt(2000)); err != nil {
+				t.Fatal(err)
+			}
+
+			tx, err := client.DepositWithShares(context.Background(), big.NewInt(1000), tt.callOpts...)
+			if err != nil {
+				t.Fatalf("DepositWithShares failed: %v", err)
+			}
+			args, err := client.contractABI.Methods["deposit0"].Inputs.Unpack(tx.Data()[4:])
+			if err != nil {
+				t.Fatalf("failed to decode calldata: %v", err)
+			}
+			if minShares := args[1].(*big.Int); minShares.Int64() != tt.wantMinShares {
+				t.Errorf("expected min shares %d, got %s", tt.wantMinShares, minShares)
+			}
+		})
+	}
+}
+
+func TestSlippageBpsValidation(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyHex := common.Bytes2Hex(crypto.FromECDSA(key))
+	if _, err := NewYieldFarmingClientWithBackend(ethtest.NewBackend(), testContractAddress, keyHex, WithDefaultSlippageBps(10001)); err == nil {
+		t.Error("expected a default slippage above 10000 bps to be rejected")
+	}
+	if _, err := NewYieldFarmingClientWithBackend(ethtest.NewBackend(), testContractAddress, keyHex, WithDefaultSlippageBps(10000)); err != nil {
+		t.Errorf("expected 10000 bps to be accepted, got %v", err)
+	}
+
+	client, backend := newTestClient(t)
+	if err := backend.SetCallResult(client.contractABI.Methods["previewDeposit"], big.NewInt(2000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DepositWithShares(context.Background(), big.NewInt(1000), WithSlippage(10001)); err == nil {
+		t.Error("expected a per-call slippage above 10000 bps to be rejected")
+	}
+	if sent := backend.SentTransactions(); len(sent) != 0 {
+		t.Errorf("expected nothing sent, got %d transactions", len(sent))
+	}
+}
+
+func TestSlippageDepositsRejectInvalidAmounts(t *testing.T) {
+	client, backend := newTestClient(t)
+	ctx := context.Background()
+
+	for _, amount := range []*big.Int{nil, big.NewInt(0), big.NewInt(-5)} {
+		if _, err := client.QuoteShares(ctx, amount); err == nil {
+			t.Errorf("QuoteShares(%v): expected an error", amount)
+		}
+		if _, err := client.DepositWithSlippage(ctx, amount, 50); err == nil {
+			t.Errorf("DepositWithSlippage(%v): expected an error", amount)
+		}
+		if _, err := client.DepositWithMinShares(ctx, amount, big.NewInt(1)); err == nil {
+			t.Errorf("DepositWithMinShares(%v): expected an error", amount)
+		}
+	}
+
+	if calls, sent := len(backend.Calls()), len(backend.SentTransactions()); calls != 0 || sent != 0 {
+		t.Errorf("expected no RPC traffic for invalid amounts, got %d calls and %d sends", calls, sent)
+	}
+}
+
+const reservesABI = `
+	{"type":"function","name":"getReserves","stateMutability":"view","inputs":[],"outputs":[{"name":"reserve0","type":"uint112"},{"name":"reserve1","type":"uint112"},{"name":"blockTimestampLast","type":"uint32"}]},
+	{"type":"function","name":"token0","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]}`
+
+func TestEstimatePriceImpact(t *testing.T) {
+	stakingToken := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	otherToken := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+
+	tests := []struct {
+		name   string
+		token0 common.Address
+		amount *big.Int
+		want   float64
+		warns  bool
+	}{
+		// 10 into a reserve of 1000 is 10/1010 of the reserve after the deposit
+		{name: "staking token is token0", token0: stakingToken, amount: tokens(10, 18), want: 100.0 * 10 / 1010, warns: false},
+		{name: "staking token is token1", token0: otherToken, amount: tokens(10, 18), want: 100.0 * 10 / 4010, warns: false},
+		{name: "large deposit", token0: stakingToken, amount: tokens(250, 18), want: 20, warns: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t, reservesABI)
+			WithStakingToken(stakingToken)(client)
+			setCallResult(t, client, backend, "getReserves", tokens(1000, 18), tokens(4000, 18), uint32(1700000000))
+			setCallResult(t, client, backend, "token0", tt.token0)
+
+			var logs bytes.Buffer
+			defer log.SetOutput(log.Writer())
+			log.SetOutput(&logs)
+
+			impact, err := client.EstimatePriceImpact(context.Background(), tt.amount)
+			if err != nil {
+				t.Fatalf("EstimatePriceImpact failed: %v", err)
+			}
+			assertFloat(t, "price impact", impact, tt.want)
+			if warned := strings.Contains(logs.String(), "consider splitting"); warned != tt.warns {
+				t.Errorf("expected warning %v, got log %q", tt.warns, logs.String())
+			}
+		})
+	}
+}
+
+func TestEstimatePriceImpactThreshold(t *testing.T) {
+	client, backend := newTestClient(t, reservesABI)
+	WithPriceImpactThreshold(0.5)(client)
+	setCallResult(t, client, backend, "getReserves", tokens(1000, 18), tokens(4000, 18), uint32(1700000000))
+
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	if _, err := client.EstimatePriceImpact(context.Background(), tokens(10, 18)); err != nil {
+		t.Fatalf("EstimatePriceImpact failed: %v", err)
+	}
+	if !strings.Contains(logs.String(), "above the 0.50% threshold") {
+		t.Errorf("expected a warning at the lowered threshold, got %q", logs.String())
+	}
+
+	client, _ = newTestClient(t)
+	if _, err := client.EstimatePriceImpact(context.Background(), tokens(10, 18)); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("expected ErrUnsupportedMethod without getReserves, got %v", err)
+	}
+}
+
+func TestZeroAmountsRejectedBeforePacking(t *testing.T) {
+	user := common.HexToAddress("0x00000000000000000000000000000000000000d1")
+	operations := map[string]func(*YieldFarmingClient, *big.Int) error{
+		"Deposit": func(c *YieldFarmingClient, amount *big.Int) error {
+			_, err := c.Deposit(context.Background(), amount)
+			return err
+		},
+		"Withdraw": func(c *YieldFarmingClient, amount *big.Int) error {
+			_, err := c.Withdraw(context.Background(), amount)
+			return err
+		},
+		"SubmitDeposit": func(c *YieldFarmingClient, amount *big.Int) error {
+			_, err := c.SubmitDeposit(context.Background(), amount)
+			return err
+		},
+		"SubmitWithdraw": func(c *YieldFarmingClient, amount *big.Int) error {
+			_, err := c.SubmitWithdraw(context.Background(), amount)
+			return err
+		},
+		"DepositFor": func(c *YieldFarmingClient, amount *big.Int) error {
+			_, err := c.DepositFor(context.Background(), user, amount)
+			return err
+		},
+		"SimulateDeposit": func(c *YieldFarmingClient, amount *big.Int) error {
+			return c.SimulateDeposit(context.Background(), amount)
+		},
+		"SimulateWithdraw": func(c *YieldFarmingClient, amount *big.Int) error {
+			return c.SimulateWithdraw(context.Background(), amount)
+		},
+	}
+
+	for name, operation := range operations {
+		for _, amount := range []*big.Int{nil, big.NewInt(0), big.NewInt(-1)} {
+			client, backend := newTestClient(t, depositForABI)
+			err := operation(client, amount)
+			if !errors.Is(err, ErrZeroAmount) || !errors.Is(err, ErrInvalidAmount) {
+				t.Errorf("%s(%v): expected ErrZeroAmount, got %v", name, amount, err)
+			}
+			if calls, sent := len(backend.Calls()), len(backend.SentTransactions()); calls != 0 || sent != 0 {
+				t.Errorf("%s(%v): expected no RPC traffic, got %d calls and %d sends", name, amount, calls, sent)
+			}
+		}
+	}
+}
+
+const depositLimitsABI = `
+	{"type":"function","name":"minDeposit","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"maxDeposit","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]}`
+
+// setCallResult registers a canned return value for a pool view method
+func setCallResult(t *testing.T, client *YieldFarmingClient, backend *ethtest.Backend, method string, values ...interface{}) {
+	t.Helper()
+
+	m, ok := client.contractABI.Methods[method]
+	if !ok {
+		t.Fatalf("test ABI has no %s method", method)
+	}
+	if err := backend.SetCallResult(m, values...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDepositValidatesLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		wantErr error
+	}{
+		{name: "below minimum", amount: 99, wantErr: ErrAmountTooSmall},
+		{name: "above maximum", amount: 1001, wantErr: ErrAmountTooLarge},
+		{name: "at minimum", amount: 100},
+		{name: "within range", amount: 500},
+		{name: "at maximum", amount: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t, depositLimitsABI)
+			setCallResult(t, client, backend, "minDeposit", big.NewInt(100))
+			setCallResult(t, client, backend, "maxDeposit", big.NewInt(1000))
+
+			_, err := client.Deposit(context.Background(), big.NewInt(tt.amount))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			wantSent := 0
+			if tt.wantErr == nil {
+				wantSent = 1
+			}
+			if sent := len(backend.SentTransactions()); sent != wantSent {
+				t.Errorf("expected %d sent transactions, got %d", wantSent, sent)
+			}
+		})
+	}
+}
+
+func TestDepositLimitsAreCachedUntilTTL(t *testing.T) {
+	client, backend := newTestClient(t, depositLimitsABI)
+	setCallResult(t, client, backend, "minDeposit", big.NewInt(100))
+	setCallResult(t, client, backend, "maxDeposit", big.NewInt(0))
+
+	now := time.Unix(1700000000, 0)
+	client.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Deposit(ctx, big.NewInt(1e18)); err != nil {
+			t.Fatalf("deposit %d failed: %v", i, err)
+		}
+	}
+	if calls := len(backend.Calls()); calls != 2 {
+		t.Fatalf("expected limits to be read once (2 calls), got %d calls", calls)
+	}
+
+	now = now.Add(defaultDepositLimitsTTL)
+	if _, err := client.Deposit(ctx, big.NewInt(1e18)); err != nil {
+		t.Fatalf("deposit after TTL failed: %v", err)
+	}
+	if calls := len(backend.Calls()); calls != 4 {
+		t.Errorf("expected limits to be refreshed after the TTL, got %d calls", calls)
+	}
+}
+
+func TestDepositLimitCheckCanBeDisabled(t *testing.T) {
+	client, backend := newTestClient(t, depositLimitsABI)
+	WithoutDepositLimitCheck()(client)
+
+	if _, err := client.Deposit(context.Background(), big.NewInt(1)); err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	if calls := len(backend.Calls()); calls != 0 {
+		t.Errorf("expected no limit reads when disabled, got %d", calls)
+	}
+}
+
+func TestFormatTokenAmount(t *testing.T) {
+	tests := []struct {
+		amount   string
+		decimals int
+		want     string
+	}{
+		{amount: "1500000", decimals: 6, want: "1.5"},
+		{amount: "1000000", decimals: 6, want: "1"},
+		{amount: "1", decimals: 6, want: "0.000001"},
+		{amount: "0", decimals: 6, want: "0"},
+		{amount: "-250000", decimals: 6, want: "-0.25"},
+		{amount: "1000000000000000000", decimals: 18, want: "1"},
+		{amount: "1230000000000000000000", decimals: 18, want: "1230"},
+		{amount: "123456789012345678", decimals: 18, want: "0.123456789012345678"},
+		{amount: "42", decimals: 0, want: "42"},
+	}
+
+	for _, tt := range tests {
+		amount, _ := new(big.Int).SetString(tt.amount, 10)
+		if got := FormatTokenAmount(amount, tt.decimals); got != tt.want {
+			t.Errorf("FormatTokenAmount(%s, %d) = %q, want %q", tt.amount, tt.decimals, got, tt.want)
+		}
+	}
+
+	if got := FormatETH(big.NewInt(5e17)); got != "0.5" {
+		t.Errorf("FormatETH(5e17) = %q, want \"0.5\"", got)
+	}
+	if got := FormatTokenAmount(nil, 18); got != "0" {
+		t.Errorf("FormatTokenAmount(nil) = %q, want \"0\"", got)
+	}
+}
+
+func TestParseTokenAmount(t *testing.T) {
+	tests := []struct {
+		input    string
+		decimals int
+		want     string
+		wantErr  bool
+	}{
+		{input: "1.5", decimals: 6, want: "1500000"},
+		{input: "0.000001", decimals: 6, want: "1"},
+		{input: "1.5000000", decimals: 6, want: "1500000"},
+		{input: "-0.25", decimals: 6, want: "-250000"},
+		{input: ".5", decimals: 18, want: "500000000000000000"},
+		{input: "1", decimals: 18, want: "1000000000000000000"},
+		{input: "0.0000001", decimals: 6, wantErr: true},
+		{input: "", decimals: 6, wantErr: true},
+		{input: "abc", decimals: 6, wantErr: true},
+		{input: "1.2.3", decimals: 6, wantErr: true},
+		{input: "1e18", decimals: 18, wantErr: true},
+		{input: "1", decimals: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTokenAmount(tt.input, tt.decimals)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseTokenAmount(%q, %d): expected an error, got %s", tt.input, tt.decimals, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTokenAmount(%q, %d): unexpected error: %v", tt.input, tt.decimals, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseTokenAmount(%q, %d) = %s, want %s", tt.input, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestTokenAmountConversions(t *testing.T) {
+	amount, err := FromTokens("1.5", 6)
+	if err != nil {
+		t.Fatalf("FromTokens failed: %v", err)
+	}
+	if amount.Wei().Int64() != 1500000 || amount.Tokens() != "1.5" || amount.Decimals() != 6 {
+		t.Errorf("unexpected amount: %s wei, %s tokens, %d decimals", amount.Wei(), amount.Tokens(), amount.Decimals())
+	}
+	if _, err := FromTokens("1.0000001", 6); err == nil {
+		t.Error("expected excess decimal places to be rejected")
+	}
+
+	amount.Wei().SetInt64(0)
+	if amount.Wei().Int64() != 1500000 {
+		t.Error("expected Wei to return a copy")
+	}
+	if zero := (TokenAmount{}); zero.Wei().Sign() != 0 || zero.Tokens() != "0" {
+		t.Errorf("expected the zero TokenAmount to be 0, got %s", zero.Wei())
+	}
+	if got := FromWei(big.NewInt(2500000), 6).Tokens(); got != "2.5" {
+		t.Errorf("FromWei(2500000, 6) = %s tokens, want 2.5", got)
+	}
+}
+
+func TestFromTokensFloatRounding(t *testing.T) {
+	zero := 0.0
+	tests := []struct {
+		input    float64
+		decimals int
+		want     string
+		wantErr  bool
+	}{
+		{input: 0.1, decimals: 18, want: "100000000000000000"},
+		{input: 1.5, decimals: 6, want: "1500000"},
+		{input: 1.23456789, decimals: 6, want: "1234568"},
+		{input: 0.0000004, decimals: 6, want: "0"},
+		{input: -2.25, decimals: 2, want: "-225"},
+		{input: 1e21, decimals: 0, want: "1000000000000000000000"},
+		{input: zero / zero, decimals: 18, wantErr: true},
+		{input: 1 / zero, decimals: 18, wantErr: true},
+		{input: 1, decimals: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := FromTokensFloat(tt.input, tt.decimals)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("FromTokensFloat(%v, %d): expected an error, got %s", tt.input, tt.decimals, got.Wei())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FromTokensFloat(%v, %d): unexpected error: %v", tt.input, tt.decimals, err)
+			continue
+		}
+		if got.Wei().String() != tt.want {
+			t.Errorf("FromTokensFloat(%v, %d) = %s, want %s", tt.input, tt.decimals, got.Wei(), tt.want)
+		}
+	}
+}
+
+func TestTokenAmountArithmetic(t *testing.T) {
+	a, _ := FromTokens("1.5", 6)
+	b, _ := FromTokens("0.25", 6)
+
+	sum, err := a.Add(b)
+	if err != nil || sum.Tokens() != "1.75" {
+		t.Errorf("1.5 + 0.25 = %s (%v), want 1.75", sum, err)
+	}
+	diff, err := b.Sub(a)
+	if err != nil || diff.Tokens() != "-1.25" {
+		t.Errorf("0.25 - 1.5 = %s (%v), want -1.25", diff, err)
+	}
+	if got := a.Mul(3).Tokens(); got != "4.5" {
+		t.Errorf("1.5 * 3 = %s, want 4.5", got)
+	}
+
+	other, _ := FromTokens("1.5", 18)
+	if _, err := a.Add(other); err == nil {
+		t.Error("expected adding amounts with different decimals to fail")
+	}
+	if a.Cmp(other) != 0 || b.Cmp(other) >= 0 || other.Cmp(b) <= 0 {
+		t.Error("expected Cmp to compare whole-token values across decimals")
+	}
+}
+
+func TestDepositTokensChecksDecimals(t *testing.T) {
+	client, backend := newTestClient(t)
+	WithStakingTokenDecimals(18)(client)
+
+	amount, _ := FromTokens("1.5", 18)
+	tx, err := client.DepositTokens(context.Background(), amount)
+	if err != nil {
+		t.Fatalf("DepositTokens failed: %v", err)
+	}
+	want, _ := client.contractABI.Pack("deposit", amount.Wei())
+	if !bytes.Equal(tx.Data(), want) {
+		t.Errorf("expected deposit of %s, got data %x", amount.Wei(), tx.Data())
+	}
+	if _, err := client.WithdrawTokens(context.Background(), amount); err != nil {
+		t.Fatalf("WithdrawTokens failed: %v", err)
+	}
+
+	usdc, _ := FromTokens("1.5", 6)
+	if _, err := client.DepositTokens(context.Background(), usdc); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("expected ErrInvalidAmount for mismatched decimals, got %v", err)
+	}
+	if _, err := client.WithdrawTokens(context.Background(), usdc); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("expected ErrInvalidAmount for mismatched decimals, got %v", err)
+	}
+	if sent := backend.SentTransactions(); len(sent) != 2 {
+		t.Errorf("expected only the matching deposit and withdrawal sent, got %d transactions", len(sent))
+	}
+}
+
+// tokens returns n whole tokens in base units for the given decimals
+func tokens(n int64, decimals int) *big.Int {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	return scale.Mul(scale, big.NewInt(n))
+}
+
+// assertFloat fails the test unless got is within a relative 1e-9 of want
+func assertFloat(t *testing.T, name string, got *big.Float, want float64) {
+	t.Helper()
+
+	g, _ := got.Float64()
+	tolerance := want * 1e-9
+	if tolerance < 0 {
+		tolerance = -tolerance
+	}
+	if diff := g - want; diff > tolerance || diff < -tolerance {
+		t.Errorf("%s = %v, want %v", name, g, want)
+	}
+}
+
+func TestCalculateAPYNormalizesDecimals(t *testing.T) {
+	// 1,000,000 base units per second is 1 USDC/sec but a negligible amount of an 18-decimal token
+	rewardRate := big.NewInt(1000000)
+	tvl := tokens(315360000, 18)
+
+	assertFloat(t, "6-decimal reward APY", calculateAPY(tvl, rewardRate, 18, 6), 10)
+	assertFloat(t, "18-decimal reward APY", calculateAPY(tvl, rewardRate, 18, 18), 10e-12)
+	assertFloat(t, "6-decimal staking APY", calculateAPY(tokens(315360000, 6), rewardRate, 6, 6), 10)
+
+	if apy := calculateAPY(big.NewInt(0), rewardRate, 18, 6); apy.Sign() != 0 {
+		t.Errorf("expected zero APY for an empty pool, got %s", apy)
+	}
+}
+
+func TestCalculateAPYDetectsRewardTokenDecimals(t *testing.T) {
+	client, backend := newTestClient(t)
+	WithStakingTokenDecimals(18)(client)
+	rewardToken := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	WithRewardToken(rewardToken)(client)
+	if err := backend.SetCallResult(erc20ABI.Methods["decimals"], uint8(6)); err != nil {
+		t.Fatal(err)
+	}
+	setPoolTVL(t, client, backend, tokens(1000, 18))
+
+	// The mock pool emits 1e18 base units per second against the 1000 staked tokens it holds
+	apy, err := client.CalculateAPY(context.Background())
+	if err != nil {
+		t.Fatalf("CalculateAPY failed: %v", err)
+	}
+	assertFloat(t, "APY", apy, 1e18/1e6*secondsPerYear/1000*100)
+
+	if _, err := client.CalculateAPY(context.Background()); err != nil {
+		t.Fatalf("second CalculateAPY failed: %v", err)
+	}
+	decimalsReads := 0
+	for _, call := range backend.Calls() {
+		if *call.To == rewardToken {
+			decimalsReads++
+		}
+	}
+	if decimalsReads != 1 {
+		t.Errorf("expected decimals to be read once, got %d calls", decimalsReads)
+	}
+}
+
+func TestCalculateAPYPerBlockRewardRate(t *testing.T) {
+	// The mock pool reports a rate of 1 token, and holds 1000 staked tokens
+	tvl := tokens(1000, 18)
+	for _, tt := range []struct {
+		name      string
+		unit      RewardRateUnit
+		blockTime time.Duration
+		perSecond *big.Int
+	}{
+		{name: "per second", unit: RewardRatePerSecond, perSecond: tokens(1, 18)},
+		{name: "per 2s block", unit: RewardRatePerBlock, blockTime: 2 * time.Second, perSecond: tokens(5, 17)},
+		{name: "per 250ms block", unit: RewardRatePerBlock, blockTime: 250 * time.Millisecond, perSecond: tokens(4, 18)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t)
+			WithStakingTokenDecimals(18)(client)
+			WithRewardTokenDecimals(18)(client)
+			WithRewardRateUnit(tt.unit)(client)
+			WithBlockTime(tt.blockTime)(client)
+			setPoolTVL(t, client, backend, tvl)
+
+			apy, err := client.CalculateAPY(context.Background())
+			if err != nil {
+				t.Fatalf("CalculateAPY failed: %v", err)
+			}
+			want, _ := calculateAPY(tvl, tt.perSecond, 18, 18).Float64()
+			assertFloat(t, "APY", apy, want)
+			if calls := backend.MethodCalls("HeaderByNumber"); calls != 0 {
+				t.Errorf("expected a configured block time not to be sampled, got %d header reads", calls)
+			}
+		})
+	}
+}
+
+func TestEstimateDilution(t *testing.T) {
+	// The mock pool emits 1 token per second against the 2000 staked tokens it holds, a
+	// 1576800% APY
+	for _, tt := range []struct {
+		name      string
+		deposit   *big.Int
+		apy       float64
+		reduction float64
+	}{
+		{"no deposit", big.NewInt(0), 1576800, 0},
+		{"doubles TVL", tokens(2000, 18), 788400, 50},
+		{"quadruples TVL", tokens(6000, 18), 394200, 75},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t)
+			WithStakingTokenDecimals(18)(client)
+			WithRewardTokenDecimals(18)(client)
+			setPoolTVL(t, client, backend, tokens(2000, 18))
+
+			apy, reduction, err := client.EstimateDilution(context.Background(), tt.deposit)
+			if err != nil {
+				t.Fatalf("EstimateDilution failed: %v", err)
+			}
+			assertFloat(t, "APY", apy, tt.apy)
+			assertFloat(t, "reduction", reduction, tt.reduction)
+		})
+	}
+}
+
+func TestEstimateDilutionRejectsNegativeDeposit(t *testing.T) {
+	client, _ := newTestClient(t)
+	if _, _, err := client.EstimateDilution(context.Background(), big.NewInt(-1)); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("expected ErrInvalidAmount, got %v", err)
+	}
+}
+
+func TestPerBlockRewardRateSamplesBlockTime(t *testing.T) {
+	client, backend := newRewardShareClient(t, tokens(1000, 18), tokens(1, 18))
+	WithRewardRateUnit(RewardRatePerBlock)(client)
+
+	// 100 blocks in 1200 seconds averages 12 seconds per block
+	backend.Head = &types.Header{Number: big.NewInt(500), Time: 1700001200, Difficulty: big.NewInt(0)}
+	backend.SetHeader(&types.Header{Number: big.NewInt(400), Time: 1700000000, Difficulty: big.NewInt(0)})
+
+	apy, err := client.CalculateAPY(context.Background())
+	if err != nil {
+		t.Fatalf("CalculateAPY failed: %v", err)
+	}
+	want, _ := calculateAPY(tokens(1000, 18), new(big.Int).Div(tokens(1, 18), big.NewInt(12)), 18, 18).Float64()
+	assertFloat(t, "APY", apy, want)
+
+	// 10 of 1000 tokens staked earn 1% of 300 blocks' rewards in an hour
+	rewards, err := client.ProjectRewards(context.Background(), client.from(), time.Hour)
+	if err != nil {
+		t.Fatalf("ProjectRewards failed: %v", err)
+	}
+	if want := new(big.Int).Div(tokens(1, 18), big.NewInt(12)); rewards.Cmp(new(big.Int).Mul(want, big.NewInt(36))) != 0 {
+		t.Errorf("expected %s, got %s", new(big.Int).Mul(want, big.NewInt(36)), rewards)
+	}
+	if calls := backend.MethodCalls("HeaderByNumber"); calls != 2 {
+		t.Errorf("expected the block time to be sampled once from 2 headers, got %d header reads", calls)
+	}
+}
+
+func TestPerBlockRewardRateNeedsBlockHistory(t *testing.T) {
+	client, backend := newTestClient(t)
+	WithStakingTokenDecimals(18)(client)
+	WithRewardTokenDecimals(18)(client)
+	WithRewardRateUnit(RewardRatePerBlock)(client)
+	backend.Head.Number = big.NewInt(0)
+
+	if _, err := client.CalculateAPY(context.Background()); err == nil || !strings.Contains(err.Error(), "block time") {
+		t.Errorf("expected a block time sampling error at genesis, got %v", err)
+	}
+}
+
+func TestCalculateAPYRequiresDecimals(t *testing.T) {
+	client, _ := newTestClient(t)
+	WithStakingTokenDecimals(18)(client)
+
+	if _, err := client.CalculateAPY(context.Background()); err == nil {
+		t.Fatal("expected an error when reward token decimals are unknown")
+	}
+}
+
+func TestFormatAPY(t *testing.T) {
+	huge, _ := new(big.Float).SetPrec(128).SetString("12345678901234567890123.456")
+	tests := []struct {
+		apy       *big.Float
+		precision int
+		want      string
+	}{
+		{apy: big.NewFloat(15.4237), precision: 2, want: "15.42%"},
+		{apy: big.NewFloat(15.4237), precision: 0, want: "15%"},
+		{apy: big.NewFloat(15.4237), precision: -1, want: "15%"},
+		{apy: big.NewFloat(0.00042), precision: 4, want: "0.0004%"},
+		{apy: big.NewFloat(0.00042), precision: 2, want: "0.00%"},
+		{apy: big.NewFloat(0.00000123), precision: 8, want: "0.00000123%"},
+		{apy: huge, precision: 1, want: "12345678901234567890123.5%"},
+		{apy: new(big.Float), precision: 2, want: "0.00%"},
+	}
+
+	for _, tt := range tests {
+		if got := formatAPY(tt.apy, tt.precision); got != tt.want {
+			t.Errorf("formatAPY(%s, %d) = %q, want %q", tt.apy.Text('g', 10), tt.precision, got, tt.want)
+		}
+	}
+}
+
+// testGauge is the boost gauge address used by the boost tests
+var testGauge = common.HexToAddress("0x00000000000000000000000000000000000000b0")
+
+// setGaugeBalances makes the test gauge report user's balance and working balance
+func setGaugeBalances(t *testing.T, backend *ethtest.Backend, balance, workingBalance *big.Int) {
+	t.Helper()
+
+	if err := backend.SetCallResultAt(testGauge, gaugeABI.Methods["balanceOf"], balance); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.SetCallResultAt(testGauge, gaugeABI.Methods["working_balances"], workingBalance); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetBoostedAPY(t *testing.T) {
+	// 1 token per second against the 1000 tokens the pool holds is a base APY of 3153600%
+	const baseAPY = 3153600
+
+	tests := []struct {
+		name           string
+		gauge          bool
+		balance        *big.Int
+		workingBalance *big.Int
+		wantBoost      float64
+	}{
+		{name: "no gauge", wantBoost: 1},
+		{name: "nothing staked", gauge: true, balance: big.NewInt(0), workingBalance: big.NewInt(0), wantBoost: 1},
+		{name: "no veToken", gauge: true, balance: tokens(100, 18), workingBalance: tokens(40, 18), wantBoost: 1},
+		{name: "partial boost", gauge: true, balance: tokens(100, 18), workingBalance: tokens(60, 18), wantBoost: 1.5},
+		{name: "max boost", gauge: true, balance: tokens(100, 18), workingBalance: tokens(100, 18), wantBoost: 2.5},
+		{name: "not checkpointed", gauge: true, balance: tokens(100, 18), workingBalance: big.NewInt(0), wantBoost: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t)
+			WithStakingTokenDecimals(18)(client)
+			WithRewardTokenDecimals(18)(client)
+			setPoolTVL(t, client, backend, tokens(1000, 18))
+			if tt.gauge {
+				WithBoostGauge(testGauge)(client)
+				setGaugeBalances(t, backend, tt.balance, tt.workingBalance)
+			}
+
+			apy, err := client.GetBoostedAPY(context.Background(), client.from())
+			if err != nil {
+				t.Fatalf("GetBoostedAPY failed: %v", err)
+			}
+			assertFloat(t, "boosted APY", apy, baseAPY*tt.wantBoost)
+		})
+	}
+}
+
+const tieredPoolABI = `{"type":"function","name":"userTier","stateMutability":"view","inputs":[{"name":"user","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"tierCount","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"tiers","stateMutability":"view","inputs":[{"name":"","type":"uint256"}],"outputs":[{"name":"threshold","type":"uint256"},{"name":"multiplier","type":"uint256"}]}`
+
+func TestGetUserTier(t *testing.T) {
+	// 1 token per second against the 1000 tokens the pool holds is a base APY of 3153600%
+	const baseAPY = 3153600
+
+	tests := []struct {
+		name           string
+		tier           int64
+		wantMultiplier float64
+		wantThreshold  *big.Int
+		wantNext       *big.Int
+	}{
+		{name: "base tier", tier: 0, wantMultiplier: 1, wantThreshold: big.NewInt(0), wantNext: tokens(1000, 18)},
+		{name: "middle tier", tier: 1, wantMultiplier: 1.5, wantThreshold: tokens(1000, 18), wantNext: tokens(10000, 18)},
+		{name: "top tier", tier: 2, wantMultiplier: 2, wantThreshold: tokens(10000, 18)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t, tieredPoolABI)
+			WithStakingTokenDecimals(18)(client)
+			WithRewardTokenDecimals(18)(client)
+			setPoolTVL(t, client, backend, tokens(1000, 18))
+			setCallResult(t, client, backend, "userTier", big.NewInt(tt.tier))
+			setCallResult(t, client, backend, "tierCount", big.NewInt(3))
+			for i, tier := range []struct {
+				threshold  *big.Int
+				multiplier int64
+			}{{big.NewInt(0), 10000}, {tokens(1000, 18), 15000}, {tokens(10000, 18), 20000}} {
+				if err := backend.SetCallResultFor(client.contractABI.Methods["tiers"], []interface{}{big.NewInt(int64(i))}, tier.threshold, big.NewInt(tier.multiplier)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			info, err := client.GetUserTier(context.Background(), client.from())
+			if err != nil {
+				t.Fatalf("GetUserTier failed: %v", err)
+			}
+			if info.Tier != uint64(tt.tier) || info.Threshold.Cmp(tt.wantThreshold) != 0 {
+				t.Errorf("expected tier %d from %s, got tier %d from %s", tt.tier, tt.wantThreshold, info.Tier, info.Threshold)
+			}
+			assertFloat(t, "multiplier", info.Multiplier, tt.wantMultiplier)
+			if (info.NextTierThreshold == nil) != (tt.wantNext == nil) || tt.wantNext != nil && info.NextTierThreshold.Cmp(tt.wantNext) != 0 {
+				t.Errorf("expected next tier threshold %v, got %v", tt.wantNext, info.NextTierThreshold)
+			}
+
+			apy, err := client.CalculateUserAPY(context.Background(), client.from())
+			if err != nil {
+				t.Fatalf("CalculateUserAPY failed: %v", err)
+			}
+			assertFloat(t, "user APY", apy, baseAPY*tt.wantMultiplier)
+		})
+	}
+}
+
+func TestCalculateUserAPYWithoutTiers(t *testing.T) {
+	client, backend := newTestClient(t)
+	WithStakingTokenDecimals(18)(client)
+	WithRewardTokenDecimals(18)(client)
+	setPoolTVL(t, client, backend, tokens(1000, 18))
+
+	if _, err := client.GetUserTier(context.Background(), client.from()); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("expected ErrUnsupportedMethod without tier views, got %v", err)
+	}
+	apy, err := client.CalculateUserAPY(context.Background(), client.from())
+	if err != nil {
+		t.Fatalf("CalculateUserAPY failed: %v", err)
+	}
+	assertFloat(t, "user APY", apy, 3153600)
+}
+
+func TestGetBoostInfo(t *testing.T) {
+	votingEscrow := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+
+	tests := []struct {
+		name       string
+		veBalance  *big.Int
+		wantNeeded *big.Int
+	}{
+		// 100 of the gauge's 1000 tokens needs 10% of the 10000 veToken supply
+		{name: "below max", veBalance: tokens(50, 18), wantNeeded: tokens(950, 18)},
+		{name: "at max", veBalance: tokens(1000, 18), wantNeeded: big.NewInt(0)},
+		{name: "above max", veBalance: tokens(2000, 18), wantNeeded: big.NewInt(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t)
+			WithBoostGauge(testGauge)(client)
+			setGaugeBalances(t, backend, tokens(100, 18), tokens(60, 18))
+			for method, value := range map[string]interface{}{
+				"working_supply": tokens(700, 18),
+				"totalSupply":    tokens(1000, 18),
+				"voting_escrow":  votingEscrow,
+			} {
+				if err := backend.SetCallResultAt(testGauge, gaugeABI.Methods[method], value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := backend.SetCallResultAt(votingEscrow, veTokenABI.Methods["balanceOf"], tt.veBalance); err != nil {
+				t.Fatal(err)
+			}
+			if err := backend.SetCallResultAt(votingEscrow, veTokenABI.Methods["totalSupply"], tokens(10000, 18)); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := client.GetBoostInfo(context.Background(), client.from())
+			if err != nil {
+				t.Fatalf("GetBoostInfo failed: %v", err)
+			}
+			assertFloat(t, "Boost", info.Boost, 1.5)
+			assertFloat(t, "MaxBoost", info.MaxBoost, 2.5)
+			if info.Balance.Cmp(tokens(100, 18)) != 0 || info.WorkingBalance.Cmp(tokens(60, 18)) != 0 || info.WorkingSupply.Cmp(tokens(700, 18)) != 0 {
+				t.Errorf("unexpected gauge balances %s, %s, %s", info.Balance, info.WorkingBalance, info.WorkingSupply)
+			}
+			if info.VeTokenNeeded.Cmp(tt.wantNeeded) != 0 {
+				t.Errorf("expected %s veToken needed, got %s", tt.wantNeeded, info.VeTokenNeeded)
+			}
+		})
+	}
+}
+
+func TestVeTokenNeededRoundsUp(t *testing.T) {
+	// 1 of 3 gauge tokens needs a third of 10 veToken, 3.33 rounded up
+	if got := veTokenNeeded(big.NewInt(1), big.NewInt(3), big.NewInt(0), big.NewInt(10)); got.Cmp(big.NewInt(4)) != 0 {
+		t.Errorf("expected 4, got %s", got)
+	}
+	if got := veTokenNeeded(big.NewInt(0), big.NewInt(3), big.NewInt(0), big.NewInt(10)); got.Sign() != 0 {
+		t.Errorf("expected nothing needed without a balance, got %s", got)
+	}
+}
+
+func TestGetBoostInfoRequiresGauge(t *testing.T) {
+	client, _ := newTestClient(t)
+	if _, err := client.GetBoostInfo(context.Background(), client.from()); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("expected ErrUnsupportedMethod, got %v", err)
+	}
+}
+
+func TestFormatAPYUsesCalculatedAPY(t *testing.T) {
+	// The mock pool emits 1 token per second against the 1000 staked tokens it holds
+	client, backend := newTestClient(t)
+	WithStakingTokenDecimals(18)(client)
+	WithRewardTokenDecimals(18)(client)
+	setPoolTVL(t, client, backend, tokens(1000, 18))
+
+	got, err := client.FormatAPY(context.Background())
+	if err != nil {
+		t.Fatalf("FormatAPY failed: %v", err)
+	}
+	if got != "3153600.00%" {
+		t.Errorf("expected 3153600.00%%, got %q", got)
+	}
+
+	WithAPYPrecision(0)(client)
+	if got, _ := client.FormatAPY(context.Background()); got != "3153600%" {
+		t.Errorf("expected 3153600%%, got %q", got)
+	}
+}
+
+func TestExitUsesContractExitMethod(t *testing.T) {
+	tests := []struct {
+		name      string
+		abi       string
+		poolID    *big.Int
+		signature string
+	}{
+		{
+			name:      "single pool",
+			abi:       `{"type":"function","name":"exit","inputs":[],"outputs":[]}`,
+			signature: "exit()",
+		},
+		{
+			name:      "pool id",
+			abi:       `{"type":"function","name":"exit","inputs":[{"name":"pid","type":"uint256"}],"outputs":[]}`,
+			poolID:    big.NewInt(3),
+			signature: "exit(uint256)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t, tt.abi)
+
+			txs, err := client.Exit(context.Background(), tt.poolID)
+			if err != nil {
+				t.Fatalf("Exit failed: %v", err)
+			}
+			if len(txs) != 1 || len(backend.SentTransactions()) != 1 {
+				t.Fatalf("expected a single exit transaction, got %d", len(txs))
+			}
+			if selector := crypto.Keccak256([]byte(tt.signature))[:4]; !bytes.Equal(txs[0].Data()[:4], selector) {
+				t.Errorf("expected %s selector %x, got %x", tt.signature, selector, txs[0].Data()[:4])
+			}
+		})
+	}
+}
+
+func TestExitFallsBackToWithdrawAndClaim(t *testing.T) {
+	client, backend := newTestClient(t)
+
+	txs, err := client.Exit(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Exit failed: %v", err)
+	}
+	if len(txs) != 2 {
+		t.Fatalf("expected withdraw and claim transactions, got %d", len(txs))
+	}
+
+	position, _ := client.GetUserPosition(context.Background(), client.auth.From)
+	wantWithdraw, _ := client.contractABI.Pack("withdraw", position.StakedBalance)
+	wantClaim, _ := client.contractABI.Pack("claimRewards")
+	if !bytes.Equal(txs[0].Data(), wantWithdraw) {
+		t.Errorf("expected the first transaction to withdraw the full balance, got %x", txs[0].Data())
+	}
+	if !bytes.Equal(txs[1].Data(), wantClaim) {
+		t.Errorf("expected the second transaction to claim rewards, got %x", txs[1].Data())
+	}
+	if txs[0].Nonce()+1 != txs[1].Nonce() {
+		t.Errorf("expected sequential nonces, got %d and %d", txs[0].Nonce(), txs[1].Nonce())
+	}
+	if sent := len(backend.SentTransactions()); sent != 2 {
+		t.Errorf("expected 2 sent transactions, got %d", sent)
+	}
+
+	if _, err := client.Exit(context.Background(), big.NewInt(1)); err == nil {
+		t.Error("expected an error when falling back with a pool id")
+	}
+}
+
+func TestProjectRewards(t *testing.T) {
+	tests := []struct {
+		name       string
+		staked     *big.Int
+		tvl        *big.Int
+		rewardRate *big.Int
+		duration   time.Duration
+		want       *big.Int
+	}{
+		{name: "tenth of pool for a day", staked: tokens(100, 18), tvl: tokens(1000, 18), rewardRate: tokens(1, 18), duration: 24 * time.Hour, want: tokens(8640, 18)},
+		{name: "whole pool for an hour", staked: big.NewInt(50), tvl: big.NewInt(50), rewardRate: big.NewInt(2), duration: time.Hour, want: big.NewInt(7200)},
+		{name: "rounds down", staked: big.NewInt(1), tvl: big.NewInt(3), rewardRate: big.NewInt(1), duration: 10 * time.Second, want: big.NewInt(3)},
+		{name: "zero stake", staked: big.NewInt(0), tvl: tokens(1000, 18), rewardRate: tokens(1, 18), duration: time.Hour, want: big.NewInt(0)},
+		{name: "empty pool", staked: big.NewInt(10), tvl: big.NewInt(0), rewardRate: big.NewInt(1), duration: time.Hour, want: big.NewInt(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := projectRewards(tt.staked, tt.tvl, tt.rewardRate, tt.duration); got.Cmp(tt.want) != 0 {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+// newRewardShareClient returns a client over a pool holding tvl staking tokens and emitting
+// rewardRate per second, whose user has 10 tokens staked and 0.5 tokens pending
+func newRewardShareClient(t *testing.T, tvl, rewardRate *big.Int) (*YieldFarmingClient, *ethtest.Backend) {
+	t.Helper()
+
+	client, backend := newTestClient(t, userInfoABI, pendingRewardsABI, emissionScheduleABI)
+	WithStakingToken(common.HexToAddress("0x00000000000000000000000000000000000000aa"))(client)
+	WithStakingTokenDecimals(18)(client)
+	WithRewardTokenDecimals(18)(client)
+	setCallResult(t, client, backend, "userInfo", tokens(10, 18), big.NewInt(0))
+	setCallResult(t, client, backend, "pendingRewards", tokens(5, 17))
+	setCallResult(t, client, backend, "rewardRate", rewardRate)
+	setCallResult(t, client, backend, "periodFinish", big.NewInt(0))
+	if err := backend.SetCallResult(erc20ABI.Methods["balanceOf"], tvl); err != nil {
+		t.Fatal(err)
+	}
+	return client, backend
+}
+
+func TestProjectRewardsUsesPosition(t *testing.T) {
+	client, _ := newRewardShareClient(t, tokens(1000, 18), tokens(1, 18))
+
+	// 10 of the pool's 1000 tokens earn 1% of 1 token per second
+	rewards, err := client.ProjectRewards(context.Background(), client.auth.From, time.Hour)
+	if err != nil {
+		t.Fatalf("ProjectRewards failed: %v", err)
+	}
+	if want := new(big.Int).Div(tokens(3600, 18), big.NewInt(100)); rewards.Cmp(want) != 0 {
+		t.Errorf("expected %s, got %s", want, rewards)
+	}
+
+	// Doubling TVL and halving emissions quarters the projection
+	client, _ = newRewardShareClient(t, tokens(2000, 18), tokens(5, 17))
+	rewards, err = client.ProjectRewards(context.Background(), client.auth.From, time.Hour)
+	if err != nil {
+		t.Fatalf("ProjectRewards failed: %v", err)
+	}
+	if want := new(big.Int).Div(tokens(3600, 18), big.NewInt(400)); rewards.Cmp(want) != 0 {
+		t.Errorf("expected %s, got %s", want, rewards)
+	}
+}
+
+func TestProjectRewardsExpiredPool(t *testing.T) {
+	client, backend := newRewardShareClient(t, tokens(1000, 18), tokens(1, 18))
+	setCallResult(t, client, backend, "periodFinish", big.NewInt(1700000000))
+	backend.Head = &types.Header{Number: big.NewInt(500), Time: 1700000001, Difficulty: big.NewInt(0)}
+
+	rewards, err := client.ProjectRewards(context.Background(), client.auth.From, time.Hour)
+	if err != nil {
+		t.Fatalf("ProjectRewards failed: %v", err)
+	}
+	if rewards.Sign() != 0 {
+		t.Errorf("expected no rewards from an expired pool, got %s", rewards)
+	}
+	if _, err := client.TimeToReachRewards(context.Background(), client.auth.From, tokens(1, 18)); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("expected ErrUnreachable, got %v", err)
+	}
+}
+
+func TestTimeToReachRewardsFixedRate(t *testing.T) {
+	tests := []struct {
+		name       string
+		remaining  *big.Int
+		staked     *big.Int
+		tvl        *big.Int
+		rewardRate *big.Int
+		want       time.Duration
+		wantErr    error
+	}{
+		{name: "tenth of pool", remaining: tokens(8640, 18), staked: tokens(100, 18), tvl: tokens(1000, 18), rewardRate: tokens(1, 18), want: 24 * time.Hour},
+		{name: "whole pool", remaining: big.NewInt(7200), staked: big.NewInt(50), tvl: big.NewInt(50), rewardRate: big.NewInt(2), want: time.Hour},
+		{name: "rounds up", remaining: big.NewInt(4), staked: big.NewInt(1), tvl: big.NewInt(3), rewardRate: big.NewInt(1), want: 12 * time.Second},
+		{name: "zero rate", remaining: big.NewInt(1), staked: big.NewInt(1), tvl: big.NewInt(1), rewardRate: big.NewInt(0), wantErr: ErrUnreachable},
+		{name: "zero stake", remaining: big.NewInt(1), staked: big.NewInt(0), tvl: big.NewInt(1), rewardRate: big.NewInt(1), wantErr: ErrUnreachable},
+		{name: "beyond duration range", remaining: tokens(1, 30), staked: big.NewInt(1), tvl: big.NewInt(1), rewardRate: big.NewInt(1), wantErr: ErrUnreachable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := timeToReachRewards(tt.remaining, tt.staked, tt.tvl, tt.rewardRate)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %s, %v", tt.wantErr, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("expected %s, got %s, %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestTimeToReachRewardsUsesPosition(t *testing.T) {
+	client, _ := newRewardShareClient(t, tokens(1000, 18), tokens(1, 18))
+
+	// The position has 0.5 tokens pending and earns 1% of 1 token per second
+	got, err := client.TimeToReachRewards(context.Background(), client.auth.From, new(big.Int).Div(tokens(15, 18), big.NewInt(10)))
+	if err != nil {
+		t.Fatalf("TimeToReachRewards failed: %v", err)
+	}
+	if got != 100*time.Second {
+		t.Errorf("expected 100s, got %s", got)
+	}
+
+	if got, err := client.TimeToReachRewards(context.Background(), client.auth.From, big.NewInt(1)); err != nil || got != 0 {
+		t.Errorf("expected a reached target to take no time, got %s, %v", got, err)
+	}
+}
+
+func TestTimeToReachRewardsWithoutStake(t *testing.T) {
+	client, backend := newRewardShareClient(t, tokens(1000, 18), tokens(1, 18))
+	setCallResult(t, client, backend, "userInfo", big.NewInt(0), big.NewInt(0))
+	setCallResult(t, client, backend, "pendingRewards", big.NewInt(0))
+
+	if _, err := client.TimeToReachRewards(context.Background(), client.auth.From, big.NewInt(1)); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("expected ErrUnreachable, got %v", err)
+	}
+}
+
+func TestSendAfterTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		sendErr   error
+		accepted  bool
+		wantSends int
+	}{
+		{name: "timed out after landing", sendErr: context.DeadlineExceeded, accepted: true, wantSends: 1},
+		{name: "timed out before landing", sendErr: &net.OpError{Op: "write", Net: "tcp", Err: os.ErrDeadlineExceeded}, wantSends: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t)
+			backend.SetSendError(tt.sendErr, tt.accepted)
+
+			tx, err := client.Deposit(context.Background(), big.NewInt(1000))
+			if err != nil {
+				t.Fatalf("Deposit failed: %v", err)
+			}
+			if got := backend.MethodCalls("TransactionByHash"); got != 1 {
+				t.Errorf("expected one lookup by hash, got %d", got)
+			}
+			if got := backend.MethodCalls("SendTransaction"); got != tt.wantSends {
+				t.Errorf("expected %d send attempts, got %d", tt.wantSends, got)
+			}
+			sent := backend.SentTransactions()
+			if len(sent) != 1 || sent[0].Hash() != tx.Hash() {
+				t.Errorf("expected only %s on chain, got %d transactions", tx.Hash().Hex(), len(sent))
+			}
+		})
+	}
+}
+
+func TestSendDoesNotResendOtherErrors(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.SetSendError(errors.New("nonce too low"), false)
+
+	if _, err := client.Deposit(context.Background(), big.NewInt(1000)); err == nil {
+		t.Fatal("expected the send error")
+	}
+	if backend.MethodCalls("TransactionByHash") != 0 || backend.MethodCalls("SendTransaction") != 1 {
+		t.Errorf("expected a single send without lookup, got %d lookups and %d sends",
+			backend.MethodCalls("TransactionByHash"), backend.MethodCalls("SendTransaction"))
+	}
+}
+
+func TestSendAfterTimeoutWithoutLookup(t *testing.T) {
+	client, backend := newTestClient(t)
+	// Embedding only EthBackend hides the fake's TransactionByHash
+	client.client = struct{ EthBackend }{backend}
+	backend.SetSendError(context.DeadlineExceeded, true)
+
+	_, err := client.Deposit(context.Background(), big.NewInt(1000))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the timeout when the send can't be checked, got %v", err)
+	}
+	if got := backend.MethodCalls("SendTransaction"); got != 1 {
+		t.Errorf("expected no blind resend, got %d sends", got)
+	}
+}
+
+// txFee returns tx's worst-case fee, its gas limit at its gas price
+func txFee(tx *types.Transaction) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), tx.GasPrice())
+}
+
+func TestGasBudgetAccumulatesAndTrips(t *testing.T) {
+	client, backend := newTestClient(t)
+	ctx := context.Background()
+
+	first, err := client.Deposit(ctx, big.NewInt(1000))
+	if err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	fee := txFee(first)
+	// Room for two sends but not a third
+	WithGasBudget(new(big.Int).Sub(new(big.Int).Mul(fee, big.NewInt(3)), big.NewInt(1)))(client)
+
+	if _, err := client.Deposit(ctx, big.NewInt(1000)); err != nil {
+		t.Fatalf("second Deposit failed: %v", err)
+	}
+	if got, want := client.GasSpent(), new(big.Int).Mul(fee, big.NewInt(2)); got.Cmp(want) != 0 {
+		t.Errorf("expected %s wei spent, got %s", want, got)
+	}
+
+	_, err = client.Deposit(ctx, big.NewInt(1000))
+	if !errors.Is(err, ErrGasBudgetExceeded) {
+		t.Fatalf("expected ErrGasBudgetExceeded, got %v", err)
+	}
+	if sent := len(backend.SentTransactions()); sent != 2 {
+		t.Errorf("expected the over-budget send to be blocked, got %d sent", sent)
+	}
+
+	client.ResetGasBudget()
+	if spent := client.GasSpent(); spent.Sign() != 0 {
+		t.Errorf("expected no gas spent after reset, got %s", spent)
+	}
+	if _, err := client.Deposit(ctx, big.NewInt(1000)); err != nil {
+		t.Fatalf("Deposit after reset failed: %v", err)
+	}
+}
+
+func TestGasBudgetSettlesActualFee(t *testing.T) {
+	client, backend := newTestClient(t)
+	WithGasBudget(tokens(1, 18))(client)
+
+	tx, err := client.Deposit(context.Background(), big.NewInt(1000))
+	if err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	if got := client.GasSpent(); got.Cmp(txFee(tx)) != 0 {
+		t.Errorf("expected the gas limit to be charged until mined, got %s", got)
+	}
+
+	backend.SetReceipt(tx.Hash(), &types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		GasUsed:           10000,
+		EffectiveGasPrice: big.NewInt(2),
+		BlockNumber:       big.NewInt(1),
+	})
+	if _, err := client.WaitForTransaction(context.Background(), tx); err != nil {
+		t.Fatalf("WaitForTransaction failed: %v", err)
+	}
+	if got := client.GasSpent(); got.Int64() != 20000 {
+		t.Errorf("expected the receipt's 20000 wei fee, got %s", got)
+	}
+}
+
+func TestGasBudgetRefundsFailedSend(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.SetSendError(errors.New("insufficient funds"), false)
+
+	if _, err := client.Deposit(context.Background(), big.NewInt(1000)); err == nil {
+		t.Fatal("expected the send error")
+	}
+	if spent := client.GasSpent(); spent.Sign() != 0 {
+		t.Errorf("expected a failed send to cost nothing, got %s", spent)
+	}
+}
+
+func TestSendChecksGasFunds(t *testing.T) {
+	client, backend := newTestClient(t)
+	ctx := context.Background()
+	// 21000 gas at 1 gwei
+	cost := big.NewInt(21000 * 1e9)
+
+	backend.SetBalance(client.from(), cost)
+	if _, err := client.Deposit(ctx, big.NewInt(1000)); err != nil {
+		t.Fatalf("expected a balance covering the gas to send, got %v", err)
+	}
+
+	backend.SetBalance(client.from(), new(big.Int).Sub(cost, big.NewInt(1)))
+	_, err := client.Deposit(ctx, big.NewInt(1000))
+	if !errors.Is(err, ErrInsufficientGasFunds) {
+		t.Fatalf("expected ErrInsufficientGasFunds, got %v", err)
+	}
+	var fundsErr *GasFundsError
+	if !errors.As(err, &fundsErr) || fundsErr.Shortfall().Int64() != 1 || fundsErr.Required.Cmp(cost) != 0 {
+		t.Errorf("expected a 1 wei shortfall of %s, got %v", cost, err)
+	}
+	if sent := len(backend.SentTransactions()); sent != 1 {
+		t.Errorf("expected the unaffordable send to be blocked, got %d sent", sent)
+	}
+	if spent := client.GasSpent(); spent.Cmp(cost) != 0 {
+		t.Errorf("expected only the first send charged, got %s", spent)
+	}
+}
+
+func TestDepositRecordsTransaction(t *testing.T) {
+	client, backend := newTestClient(t)
+	store := NewMemoryTxStore()
+	WithTxStore(store)(client)
+
+	now := time.Unix(1700000000, 0)
+	client.now = func() time.Time { return now }
+
+	amount := big.NewInt(1000)
+	tx, err := client.Deposit(context.Background(), amount)
+	if err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+
+	records, err := store.List(TxFilter{Method: "deposit"})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 deposit record, got %d", len(records))
+	}
+
+	record := records[0]
+	if record.Hash != tx.Hash() || record.Amount.Cmp(amount) != 0 || record.Status != TxStatusPending {
+		t.Errorf("unexpected record: %+v", record)
+	}
+	if record.GasLimit != backend.GasLimit || record.GasPrice.Cmp(backend.GasPrice) != 0 || !record.Timestamp.Equal(now) {
+		t.Errorf("unexpected gas or timestamp in record: %+v", record)
+	}
+
+	backend.SetReceipt(tx.Hash(), &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: tx.Hash(), GasUsed: 18000, BlockNumber: big.NewInt(2)})
+	if _, err := client.WaitForTransaction(context.Background(), tx); err != nil {
+		t.Fatalf("WaitForTransaction failed: %v", err)
+	}
+
+	records, _ = store.List(TxFilter{Status: TxStatusMined})
+	if len(records) != 1 || records[0].GasUsed != 18000 {
+		t.Errorf("expected the record to be updated from the receipt, got %+v", records)
+	}
+}
+
+// failingTxStore rejects every write
+type failingTxStore struct{}
+
+func (failingTxStore) Save(TxRecord) error { return errors.New("disk full") }
+
+func (failingTxStore) List(TxFilter) ([]TxRecord, error) { return nil, errors.New("disk full") }
+
+func TestTxStoreErrorsDoNotFailSends(t *testing.T) {
+	client, backend := newTestClient(t)
+	WithTxStore(failingTxStore{})(client)
+
+	if _, err := client.ClaimRewards(context.Background()); err != nil {
+		t.Fatalf("expected the claim to succeed despite store errors, got %v", err)
+	}
+	if sent := len(backend.SentTransactions()); sent != 1 {
+		t.Errorf("expected the claim to be sent, got %d transactions", sent)
+	}
+}
+
+func TestTxFilterMatches(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	record := TxRecord{Method: "withdraw", Status: TxStatusMined, Timestamp: base}
+
+	tests := []struct {
+		name   string
+		filter TxFilter
+		want   bool
+	}{
+		{name: "empty", filter: TxFilter{}, want: true},
+		{name: "method", filter: TxFilter{Method: "withdraw"}, want: true},
+		{name: "other method", filter: TxFilter{Method: "deposit"}, want: false},
+		{name: "other status", filter: TxFilter{Status: TxStatusPending}, want: false},
+		{name: "within window", filter: TxFilter{Since: base.Add(-time.Hour), Until: base.Add(time.Hour)}, want: true},
+		{name: "before window", filter: TxFilter{Since: base.Add(time.Second)}, want: false},
+		{name: "after window", filter: TxFilter{Until: base.Add(-time.Second)}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.Matches(record); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestClientIsSafeForConcurrentUse(t *testing.T) {
+	client, backend := newTestClient(t, depositLimitsABI)
+	setCallResult(t, client, backend, "minDeposit", big.NewInt(1))
+	setCallResult(t, client, backend, "maxDeposit", big.NewInt(0))
+	WithStakingTokenDecimals(18)(client)
+	WithRewardToken(common.HexToAddress("0x01"))(client)
+	if err := backend.SetCallResult(erc20ABI.Methods["decimals"], uint8(18)); err != nil {
+		t.Fatal(err)
+	}
+	setPoolTVL(t, client, backend, tokens(1000, 18))
+	if err := backend.SetCallResult(erc20ABI.Methods["allowance"], big.NewInt(100)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make(chan error, 70)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetUserPosition(ctx, client.auth.From); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Deposit(ctx, big.NewInt(100)); err != nil {
+				errs <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := client.CalculateAPY(ctx); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent call failed: %v", err)
+	}
+
+	nonces := make(map[uint64]bool)
+	for _, tx := range backend.SentTransactions() {
+		if nonces[tx.Nonce()] {
+			t.Errorf("nonce %d was used twice", tx.Nonce())
+		}
+		nonces[tx.Nonce()] = true
+	}
+	if len(nonces) != 10 {
+		t.Errorf("expected 10 distinct nonces, got %d", len(nonces))
+	}
+}
+
+const lockupABI = `{"type":"function","name":"unlockTime","stateMutability":"view","inputs":[{"name":"user","type":"address"}],"outputs":[{"name":"","type":"uint256"}]}`
+
+func TestWithdrawRespectsLockup(t *testing.T) {
+	unlock := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name      string
+		blockTime uint64
+		wantErr   bool
+	}{
+		{name: "locked", blockTime: uint64(unlock.Unix()) - 1, wantErr: true},
+		{name: "unlock block", blockTime: uint64(unlock.Unix())},
+		{name: "unlocked", blockTime: uint64(unlock.Unix()) + 3600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t, lockupABI)
+			setCallResult(t, client, backend, "unlockTime", big.NewInt(unlock.Unix()))
+			backend.Head.Time = tt.blockTime
+
+			canWithdraw, unlockTime, err := client.CanWithdraw(context.Background(), client.auth.From)
+			if err != nil {
+				t.Fatalf("CanWithdraw failed: %v", err)
+			}
+			if canWithdraw == tt.wantErr || !unlockTime.Equal(unlock) {
+				t.Errorf("CanWithdraw = %v, %s", canWithdraw, unlockTime)
+			}
+
+			_, err = client.Withdraw(context.Background(), big.NewInt(1))
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Withdraw failed: %v", err)
+				}
+				return
+			}
+
+			var lockedErr *LockedError
+			if !errors.Is(err, ErrStillLocked) || !errors.As(err, &lockedErr) {
+				t.Fatalf("expected ErrStillLocked, got %v", err)
+			}
+			if !lockedErr.UnlockTime.Equal(unlock) {
+				t.Errorf("expected unlock time %s, got %s", unlock, lockedErr.UnlockTime)
+			}
+			if sent := len(backend.SentTransactions()); sent != 0 {
+				t.Errorf("expected no transaction while locked, got %d", sent)
+			}
+		})
+	}
+}
+
+func TestCanWithdrawWithoutLockup(t *testing.T) {
+	client, backend := newTestClient(t, lockupABI)
+	setCallResult(t, client, backend, "unlockTime", big.NewInt(0))
+
+	canWithdraw, unlockTime, err := client.CanWithdraw(context.Background(), client.auth.From)
+	if err != nil || !canWithdraw || !unlockTime.IsZero() {
+		t.Fatalf("expected an unlocked position, got %v %s %v", canWithdraw, unlockTime, err)
+	}
+}
+
+const (
+	lastDepositTimeABI = `{"type":"function","name":"lastDepositTime","stateMutability":"view","inputs":[{"name":"user","type":"address"}],"outputs":[{"name":"","type":"uint256"}]}`
+	depositCooldownABI = `{"type":"function","name":"depositCooldown","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]}`
+)
+
+func TestDepositRespectsCooldown(t *testing.T) {
+	lastDeposit := uint64(1700000000)
+
+	tests := []struct {
+		name          string
+		blockTime     uint64
+		wantRemaining time.Duration
+	}{
+		{name: "in cooldown", blockTime: lastDeposit + 600, wantRemaining: 5 * time.Minute},
+		{name: "cooldown end", blockTime: lastDeposit + 900},
+		{name: "ready", blockTime: lastDeposit + 3600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t, lastDepositTimeABI, depositCooldownABI)
+			setCallResult(t, client, backend, "lastDepositTime", new(big.Int).SetUint64(lastDeposit))
+			setCallResult(t, client, backend, "depositCooldown", big.NewInt(900))
+			backend.Head.Time = tt.blockTime
+
+			canDeposit, remaining, err := client.CanDeposit(context.Background(), client.auth.From)
+			if err != nil {
+				t.Fatalf("CanDeposit failed: %v", err)
+			}
+			if canDeposit != (tt.wantRemaining == 0) || remaining != tt.wantRemaining {
+				t.Errorf("CanDeposit = %v, %s", canDeposit, remaining)
+			}
+
+			_, err = client.Deposit(context.Background(), big.NewInt(1))
+			if tt.wantRemaining == 0 {
+				if err != nil {
+					t.Fatalf("Deposit failed: %v", err)
+				}
+				return
+			}
+
+			var cooldownErr *DepositCooldownError
+			if !errors.Is(err, ErrDepositCooldown) || !errors.As(err, &cooldownErr) {
+				t.Fatalf("expected ErrDepositCooldown, got %v", err)
+			}
+			if cooldownErr.Remaining != tt.wantRemaining {
+				t.Errorf("expected %s remaining, got %s", tt.wantRemaining, cooldownErr.Remaining)
+			}
+			if sent := len(backend.SentTransactions()); sent != 0 {
+				t.Errorf("expected no transaction during cooldown, got %d", sent)
+			}
+		})
+	}
+}
+
+func TestDepositForRespectsBeneficiaryCooldown(t *testing.T) {
+	lastDeposit := uint64(1700000000)
+	user := common.HexToAddress("0x00000000000000000000000000000000000000d1")
+
+	client, backend := newTestClient(t, depositForABI, lastDepositTimeABI, depositCooldownABI)
+	if err := backend.SetCallResultFor(client.contractABI.Methods["lastDepositTime"], []interface{}{user}, new(big.Int).SetUint64(lastDeposit)); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.SetCallResultFor(client.contractABI.Methods["lastDepositTime"], []interface{}{client.from()}, big.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+	setCallResult(t, client, backend, "depositCooldown", big.NewInt(900))
+	backend.Head.Time = lastDeposit + 600
+
+	// The signer has never deposited, so only the beneficiary's cooldown applies
+	_, err := client.DepositFor(context.Background(), user, big.NewInt(1))
+	var cooldownErr *DepositCooldownError
+	if !errors.Is(err, ErrDepositCooldown) || !errors.As(err, &cooldownErr) {
+		t.Fatalf("expected ErrDepositCooldown, got %v", err)
+	}
+	if cooldownErr.Remaining != 5*time.Minute {
+		t.Errorf("expected 5m0s remaining, got %s", cooldownErr.Remaining)
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected no transaction during cooldown, got %d", sent)
+	}
+
+	backend.Head.Time = lastDeposit + 900
+	if _, err := client.DepositFor(context.Background(), user, big.NewInt(1)); err != nil {
+		t.Fatalf("DepositFor after the cooldown failed: %v", err)
+	}
+}
+
+func TestGetDepositCooldownWithoutCooldown(t *testing.T) {
+	t.Run("no views", func(t *testing.T) {
+		client, _ := newTestClient(t)
+		remaining, err := client.GetDepositCooldown(context.Background(), client.auth.From)
+		if err != nil || remaining != 0 {
+			t.Fatalf("expected no cooldown, got %s %v", remaining, err)
+		}
+	})
+
+	t.Run("never deposited", func(t *testing.T) {
+		client, backend := newTestClient(t, lastDepositTimeABI, depositCooldownABI)
+		setCallResult(t, client, backend, "lastDepositTime", big.NewInt(0))
+		setCallResult(t, client, backend, "depositCooldown", big.NewInt(900))
+		backend.Head.Time = 100
+
+		canDeposit, remaining, err := client.CanDeposit(context.Background(), client.auth.From)
+		if err != nil || !canDeposit || remaining != 0 {
+			t.Fatalf("expected a first deposit to be allowed, got %v %s %v", canDeposit, remaining, err)
+		}
+	})
+}
+
+// masterChefABI exposes pool-indexed deposits in place of the single-pool overloads
+const masterChefABI = `[
+	{"type":"function","name":"deposit","inputs":[{"name":"pid","type":"uint256"},{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"multicall","inputs":[{"name":"data","type":"bytes[]"}],"outputs":[{"name":"results","type":"bytes[]"}]}
+]`
+
+// newMasterChefClient returns a test client for a multi-pool contract with a configured staking token
+func newMasterChefClient(t *testing.T, definition string) (*YieldFarmingClient, *ethtest.Backend) {
+	t.Helper()
+
+	client, backend := newTestClient(t)
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.contractABI = parsed
+	WithStakingToken(common.HexToAddress("0x02"))(client)
+	return client, backend
+}
+
+const depositETHABI = `{"type":"function","name":"depositETH","stateMutability":"payable","inputs":[],"outputs":[]}`
+
+func TestDepositETHSendsValueToPayableMethod(t *testing.T) {
+	client, backend := newTestClient(t, depositETHABI)
+	amount := tokens(2, 18)
+
+	tx, err := client.DepositETH(context.Background(), amount)
+	if err != nil {
+		t.Fatalf("DepositETH failed: %v", err)
+	}
+	if tx.Value().Cmp(amount) != 0 {
+		t.Errorf("expected value %s, got %s", amount, tx.Value())
+	}
+	if want := client.contractABI.Methods["depositETH"].ID; !bytes.Equal(tx.Data(), want) || *tx.To() != testContractAddress {
+		t.Errorf("expected depositETH() to the pool, got %x to %s", tx.Data(), tx.To().Hex())
+	}
+	if sent := backend.SentTransactions(); len(sent) != 1 {
+		t.Errorf("expected a single transaction, got %d", len(sent))
+	}
+}
+
+func TestWithValueSendsETH(t *testing.T) {
+	client, backend := newTestClient(t)
+	value := tokens(1, 17)
+
+	tx, err := client.Deposit(context.Background(), big.NewInt(1000), WithValue(value))
+	if err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	if tx.Value().Cmp(value) != 0 {
+		t.Errorf("expected value %s, got %s", value, tx.Value())
+	}
+	if _, err := types.Sender(types.NewEIP155Signer(big.NewInt(1)), tx); err != nil {
+		t.Errorf("expected a signed transaction: %v", err)
+	}
+
+	tx, err = client.ClaimRewards(context.Background())
+	if err != nil {
+		t.Fatalf("ClaimRewards failed: %v", err)
+	}
+	if tx.Value().Sign() != 0 {
+		t.Errorf("expected no value by default, got %s", tx.Value())
+	}
+
+	if _, err := client.Deposit(context.Background(), big.NewInt(1000), WithValue(big.NewInt(-1))); err == nil {
+		t.Error("expected a negative value to be rejected")
+	}
+	if sent := backend.SentTransactions(); len(sent) != 2 {
+		t.Errorf("expected 2 sent transactions, got %d", len(sent))
+	}
+}
+
+func TestWithValueAppliesToSimulation(t *testing.T) {
+	client, backend := newTestClient(t)
+	name, _ := client.findOverload("deposit", 1)
+	if err := backend.SetCallResult(client.contractABI.Methods[name]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.SimulateDeposit(context.Background(), big.NewInt(1000), WithValue(big.NewInt(5))); err != nil {
+		t.Fatalf("SimulateDeposit failed: %v", err)
+	}
+	if value := backend.Calls()[0].Value; value.Int64() != 5 {
+		t.Errorf("expected the simulation to send 5 wei, got %s", value)
+	}
+}
+
+func TestDepositETHWrapsThenStakes(t *testing.T) {
+	weth := common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	client, backend := newTestClient(t)
+	WithStakingToken(weth)(client)
+	amount := tokens(2, 18)
+	if err := backend.SetCallResult(erc20ABI.Methods["allowance"], amount); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := client.DepositETH(context.Background(), amount)
+	if err != nil {
+		t.Fatalf("DepositETH failed: %v", err)
+	}
+
+	sent := backend.SentTransactions()
+	if len(sent) != 2 {
+		t.Fatalf("expected a wrap and a deposit, got %d transactions", len(sent))
+	}
+	wrap := sent[0]
+	if *wrap.To() != weth || wrap.Value().Cmp(amount) != 0 || !bytes.Equal(wrap.Data(), wethABI.Methods["deposit"].ID) {
+		t.Errorf("expected deposit() to WETH with value %s, got %x to %s with value %s", amount, wrap.Data(), wrap.To().Hex(), wrap.Value())
+	}
+	want, _ := client.contractABI.Pack("deposit", amount)
+	if tx.Hash() != sent[1].Hash() || *tx.To() != testContractAddress || tx.Value().Sign() != 0 || !bytes.Equal(tx.Data(), want) {
+		t.Errorf("expected the returned deposit to stake %s WETH without value, got %x with value %s", amount, tx.Data(), tx.Value())
+	}
+}
+
+func TestDepositETHAppliesCallOptionsToWrap(t *testing.T) {
+	weth := common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	client, backend := newTestClient(t)
+	WithStakingToken(weth)(client)
+	amount := tokens(2, 18)
+	if err := backend.SetCallResult(erc20ABI.Methods["allowance"], amount); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.DepositETH(context.Background(), amount, WithGasPrice(big.NewInt(42e9)), WithEstimateAt(rpc.LatestBlockNumber), withNonce(5))
+	if err != nil {
+		t.Fatalf("DepositETH failed: %v", err)
+	}
+
+	sent := backend.SentTransactions()
+	if len(sent) != 2 {
+		t.Fatalf("expected a wrap and a deposit, got %d transactions", len(sent))
+	}
+	for i, tx := range sent {
+		if tx.GasPrice().Int64() != 42e9 {
+			t.Errorf("transaction %d: expected gas price 42 gwei, got %s", i, tx.GasPrice())
+		}
+		if tx.Nonce() != uint64(5+i) {
+			t.Errorf("transaction %d: expected nonce %d, got %d", i, 5+i, tx.Nonce())
+		}
+	}
+	if *sent[0].To() != weth || sent[0].Value().Cmp(amount) != 0 {
+		t.Errorf("expected the wrap to send %s wei to WETH, got %s to %s", amount, sent[0].Value(), sent[0].To().Hex())
+	}
+	if blocks := backend.EstimateBlocks(); len(blocks) != 2 || blocks[0] != rpc.LatestBlockNumber || blocks[1] != rpc.LatestBlockNumber {
+		t.Errorf("expected both estimates against the latest block, got %v", blocks)
+	}
+}
+
+func TestDepositETHChecksAllowanceBeforeWrapping(t *testing.T) {
+	client, backend := newTestClient(t)
+	WithStakingToken(common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"))(client)
+	if err := backend.SetCallResult(erc20ABI.Methods["allowance"], big.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.DepositETH(context.Background(), tokens(2, 18)); !errors.Is(err, ErrInsufficientAllowance) {
+		t.Fatalf("expected ErrInsufficientAllowance, got %v", err)
+	}
+	if sent := backend.SentTransactions(); len(sent) != 0 {
+		t.Errorf("expected no ETH wrapped, got %d transactions", len(sent))
+	}
+}
+
+func TestBatchDepositPacksMulticall(t *testing.T) {
+	client, backend := newMasterChefClient(t, masterChefABI)
+	if err := backend.SetCallResult(erc20ABI.Methods["allowance"], big.NewInt(300)); err != nil {
+		t.Fatal(err)
+	}
+
+	deposits := []PoolDeposit{
+		{PoolID: big.NewInt(0), Amount: big.NewInt(100)},
+		{PoolID: big.NewInt(4), Amount: big.NewInt(200)},
+	}
+	txs, err := client.BatchDeposit(context.Background(), deposits)
+	if err != nil {
+		t.Fatalf("BatchDeposit failed: %v", err)
+	}
+	if len(txs) != 1 {
+		t.Fatalf("expected a single multicall transaction, got %d", len(txs))
+	}
+
+	multicall := client.contractABI.Methods["multicall"]
+	if !bytes.Equal(txs[0].Data()[:4], multicall.ID) {
+		t.Fatalf("expected multicall selector, got %x", txs[0].Data()[:4])
+	}
+	args, err := multicall.Inputs.Unpack(txs[0].Data()[4:])
+	if err != nil {
+		t.Fatalf("failed to decode multicall: %v", err)
+	}
+
+	calls := args[0].([][]byte)
+	if len(calls) != len(deposits) {
+		t.Fatalf("expected %d encoded deposits, got %d", len(deposits), len(calls))
+	}
+	for i, deposit := range deposits {
+		want, _ := client.contractABI.Pack("deposit", deposit.PoolID, deposit.Amount)
+		if !bytes.Equal(calls[i], want) {
+			t.Errorf("call %d: expected %x, got %x", i, want, calls[i])
+		}
+	}
+	if sent := len(backend.SentTransactions()); sent != 1 {
+		t.Errorf("expected 1 sent transaction, got %d", sent)
+	}
+}
+
+func TestBatchDepositRequiresAllowanceForTotal(t *testing.T) {
+	client, backend := newMasterChefClient(t, masterChefABI)
+	if err := backend.SetCallResult(erc20ABI.Methods["allowance"], big.NewInt(299)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.BatchDeposit(context.Background(), []PoolDeposit{
+		{PoolID: big.NewInt(0), Amount: big.NewInt(100)},
+		{PoolID: big.NewInt(1), Amount: big.NewInt(200)},
+	})
+	var allowanceErr *AllowanceError
+	if !errors.As(err, &allowanceErr) {
+		t.Fatalf("expected an AllowanceError, got %v", err)
+	}
+	if allowanceErr.Shortfall().Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("expected shortfall 1, got %s", allowanceErr.Shortfall())
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected nothing to be sent, got %d", sent)
+	}
+}
+
+func TestBatchDepositRunsDepositChecks(t *testing.T) {
+	client, backend := newMasterChefClient(t, strings.TrimSuffix(masterChefABI, "]")+","+emissionScheduleABI+"]")
+	WithExpiredPoolCheck()(client)
+	setCallResult(t, client, backend, "periodFinish", big.NewInt(1700000000))
+	backend.Head = &types.Header{Number: big.NewInt(500), Time: 1700000001, Difficulty: big.NewInt(0)}
+	if _, err := client.BatchDeposit(context.Background(), []PoolDeposit{{PoolID: big.NewInt(0), Amount: big.NewInt(100)}}); !errors.Is(err, ErrPoolExpired) {
+		t.Errorf("expected ErrPoolExpired, got %v", err)
+	}
+
+	client, backend = newMasterChefClient(t, strings.TrimSuffix(masterChefABI, "]")+","+lastDepositTimeABI+","+depositCooldownABI+"]")
+	setCallResult(t, client, backend, "lastDepositTime", big.NewInt(1700000000))
+	setCallResult(t, client, backend, "depositCooldown", big.NewInt(900))
+	backend.Head.Time = 1700000600
+	if _, err := client.BatchDeposit(context.Background(), []PoolDeposit{{PoolID: big.NewInt(0), Amount: big.NewInt(100)}}); !errors.Is(err, ErrDepositCooldown) {
+		t.Errorf("expected ErrDepositCooldown, got %v", err)
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected nothing to be sent, got %d", sent)
+	}
+}
+
+func TestBatchDepositFallsBackWithoutMulticall(t *testing.T) {
+	client, backend := newMasterChefClient(t, `[{"type":"function","name":"deposit","inputs":[{"name":"pid","type":"uint256"},{"name":"amount","type":"uint256"}],"outputs":[]}]`)
+	if err := backend.SetCallResult(erc20ABI.Methods["allowance"], big.NewInt(1000)); err != nil {
+		t.Fatal(err)
+	}
+
+	txs, err := client.BatchDeposit(context.Background(), []PoolDeposit{
+		{PoolID: big.NewInt(0), Amount: big.NewInt(100)},
+		{PoolID: big.NewInt(1), Amount: big.NewInt(200)},
+	})
+	if err != nil {
+		t.Fatalf("BatchDeposit failed: %v", err)
+	}
+	if len(txs) != 2 || txs[0].Nonce()+1 != txs[1].Nonce() {
+		t.Fatalf("expected two sequential deposit transactions, got %d", len(txs))
+	}
+}
+
+func TestBatchDepositAppliesCallOptions(t *testing.T) {
+	deposits := []PoolDeposit{
+		{PoolID: big.NewInt(0), Amount: big.NewInt(100)},
+		{PoolID: big.NewInt(1), Amount: big.NewInt(200)},
+	}
+
+	tests := []struct {
+		name   string
+		abiStr string
+		wantTx int
+	}{
+		{name: "multicall", abiStr: masterChefABI, wantTx: 1},
+		{name: "fallback", abiStr: `[{"type":"function","name":"deposit","inputs":[{"name":"pid","type":"uint256"},{"name":"amount","type":"uint256"}],"outputs":[]}]`, wantTx: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newMasterChefClient(t, tt.abiStr)
+			if err := backend.SetCallResult(erc20ABI.Methods["allowance"], big.NewInt(1000)); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := client.BatchDeposit(context.Background(), deposits, WithGasPrice(big.NewInt(42e9)), withNonce(5)); err != nil {
+				t.Fatalf("BatchDeposit failed: %v", err)
+			}
+			sent := backend.SentTransactions()
+			if len(sent) != tt.wantTx {
+				t.Fatalf("expected %d transactions, got %d", tt.wantTx, len(sent))
+			}
+			for i, tx := range sent {
+				if tx.GasPrice().Int64() != 42e9 {
+					t.Errorf("transaction %d: expected gas price 42 gwei, got %s", i, tx.GasPrice())
+				}
+				if tx.Nonce() != uint64(5+i) {
+					t.Errorf("transaction %d: expected nonce %d, got %d", i, 5+i, tx.Nonce())
+				}
+			}
+		})
+	}
+}
+
+const masterChefClaimABI = `[
+	{"type":"function","name":"claimRewards","inputs":[{"name":"pid","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"pendingRewards","stateMutability":"view","inputs":[{"name":"pid","type":"uint256"},{"name":"user","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"multicall","inputs":[{"name":"data","type":"bytes[]"}],"outputs":[{"name":"results","type":"bytes[]"}]}
+]`
+
+// setPoolPendingRewards registers the signer's pending rewards per pool
+func setPoolPendingRewards(t *testing.T, client *YieldFarmingClient, backend *ethtest.Backend, pending map[int64]int64) {
+	t.Helper()
+
+	method := client.contractABI.Methods["pendingRewards"]
+	for pid, rewards := range pending {
+		if err := backend.SetCallResultFor(method, []interface{}{big.NewInt(pid), client.from()}, big.NewInt(rewards)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestClaimAllRewardsPacksMulticallSkippingEmptyPools(t *testing.T) {
+	client, backend := newMasterChefClient(t, masterChefClaimABI)
+	setPoolPendingRewards(t, client, backend, map[int64]int64{0: 500, 3: 0, 7: 20})
+
+	tx, err := client.ClaimAllRewards(context.Background(), []*big.Int{big.NewInt(0), big.NewInt(3), big.NewInt(7)})
+	if err != nil {
+		t.Fatalf("ClaimAllRewards failed: %v", err)
+	}
+
+	multicall := client.contractABI.Methods["multicall"]
+	if !bytes.Equal(tx.Data()[:4], multicall.ID) {
+		t.Fatalf("expected multicall selector, got %x", tx.Data()[:4])
+	}
+	args, err := multicall.Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		t.Fatalf("failed to decode multicall: %v", err)
+	}
+	calls := args[0].([][]byte)
+	if len(calls) != 2 {
+		t.Fatalf("expected claims for the 2 pools with rewards, got %d", len(calls))
+	}
+	for i, pid := range []int64{0, 7} {
+		want, _ := client.contractABI.Pack("claimRewards", big.NewInt(pid))
+		if !bytes.Equal(calls[i], want) {
+			t.Errorf("call %d: expected a claim for pool %d, got %x", i, pid, calls[i])
+		}
+	}
+}
+
+func TestClaimAllRewardsUsesClaimAll(t *testing.T) {
+	for _, method := range []string{"claimAll", "massHarvest"} {
+		t.Run(method, func(t *testing.T) {
+			client, backend := newMasterChefClient(t, `[
+				{"type":"function","name":"`+method+`","inputs":[{"name":"pids","type":"uint256[]"}],"outputs":[]},
+				{"type":"function","name":"pendingRewards","stateMutability":"view","inputs":[{"name":"pid","type":"uint256"},{"name":"user","type":"address"}],"outputs":[{"name":"","type":"uint256"}]}
+			]`)
+			setPoolPendingRewards(t, client, backend, map[int64]int64{1: 0, 2: 9})
+
+			tx, err := client.ClaimAllRewards(context.Background(), []*big.Int{big.NewInt(1), big.NewInt(2)})
+			if err != nil {
+				t.Fatalf("ClaimAllRewards failed: %v", err)
+			}
+			want, _ := client.contractABI.Pack(method, []*big.Int{big.NewInt(2)})
+			if !bytes.Equal(tx.Data(), want) {
+				t.Errorf("expected %s for pool 2 only, got %x", method, tx.Data())
+			}
+		})
+	}
+}
+
+func TestClaimAllRewardsNothingPending(t *testing.T) {
+	client, backend := newMasterChefClient(t, masterChefClaimABI)
+	setPoolPendingRewards(t, client, backend, map[int64]int64{0: 0, 1: 0})
+
+	_, err := client.ClaimAllRewards(context.Background(), []*big.Int{big.NewInt(0), big.NewInt(1)})
+	if !errors.Is(err, ErrNoPendingRewards) {
+		t.Fatalf("expected ErrNoPendingRewards, got %v", err)
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected nothing sent, got %d transactions", sent)
+	}
+}
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name          string
+		rpcErr        error
+		code          []byte
+		checkContract bool
+		wantErr       error
+	}{
+		{name: "reachable", checkContract: false},
+		{name: "unreachable", rpcErr: errors.New("dial tcp: connection refused"), wantErr: errors.New("any")},
+		{name: "deployed contract", code: []byte{0x60, 0x80}, checkContract: true},
+		{name: "undeployed contract", checkContract: true, wantErr: ErrContractNotDeployed},
+		{name: "undeployed contract unchecked", checkContract: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t)
+			backend.Err = tt.rpcErr
+			backend.SetCode(testContractAddress, tt.code)
+
+			err := client.Ping(context.Background(), tt.checkContract)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != nil && err == nil:
+				t.Fatal("expected an error")
+			case errors.Is(tt.wantErr, ErrContractNotDeployed) && !errors.Is(err, ErrContractNotDeployed):
+				t.Fatalf("expected ErrContractNotDeployed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReadsFailOnceContractCodeIsGone(t *testing.T) {
+	client, backend := newTestClient(t, userInfoABI, pendingRewardsABI)
+	now := time.Unix(1700000000, 0)
+	client.now = func() time.Time { return now }
+	user := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	setCallResult(t, client, backend, "userInfo", tokens(10, 18), big.NewInt(0))
+	setCallResult(t, client, backend, "pendingRewards", big.NewInt(5))
+
+	if _, err := client.GetUserPosition(context.Background(), user); err != nil {
+		t.Fatalf("expected reads to succeed with code present, got %v", err)
+	}
+
+	// The check is cached, so a selfdestruct goes unnoticed until it expires
+	backend.SetCode(testContractAddress, nil)
+	if _, err := client.GetUserPosition(context.Background(), user); err != nil {
+		t.Fatalf("expected the cached check to pass, got %v", err)
+	}
+
+	now = now.Add(codeCheckTTL)
+	if _, err := client.GetUserPosition(context.Background(), user); !errors.Is(err, ErrContractGone) {
+		t.Fatalf("expected ErrContractGone once the check expired, got %v", err)
+	}
+	if _, err := client.GetPendingRewardsAt(context.Background(), user, big.NewInt(1)); err != nil {
+		t.Errorf("expected historical reads to skip the check, got %v", err)
+	}
+
+	backend.SetCode(testContractAddress, testPoolCode)
+	if _, err := client.GetUserPosition(context.Background(), user); err != nil {
+		t.Errorf("expected reads to recover once code is back, got %v", err)
+	}
+}
+
+func TestContractVerificationAtConstruction(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyHex := common.Bytes2Hex(crypto.FromECDSA(key))
+
+	backend := ethtest.NewBackend()
+	eoa := crypto.PubkeyToAddress(key.PublicKey)
+	backend.SetCode(testContractAddress, []byte{0x60, 0x80, 0x60, 0x40})
+
+	if _, err := NewYieldFarmingClientWithBackend(backend, eoa, keyHex, WithContractVerification()); !errors.Is(err, ErrNoContractCode) {
+		t.Errorf("expected ErrNoContractCode for an EOA, got %v", err)
+	}
+	if _, err := NewYieldFarmingClientWithBackend(backend, testContractAddress, keyHex, WithContractVerification()); err != nil {
+		t.Errorf("expected a contract address to verify, got %v", err)
+	}
+	if _, err := NewYieldFarmingClientWithBackend(backend, eoa, keyHex); err != nil {
+		t.Errorf("expected verification to be opt-in, got %v", err)
+	}
+}
+
+func TestClaimRewardsIfAbove(t *testing.T) {
+	// The mock position has 0.5 reward tokens pending
+	tests := []struct {
+		name      string
+		threshold *big.Int
+		wantErr   bool
+	}{
+		{name: "below threshold", threshold: tokens(1, 18), wantErr: true},
+		{name: "equal to threshold", threshold: big.NewInt(5e17), wantErr: true},
+		{name: "above threshold", threshold: big.NewInt(1e17)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t)
+
+			_, err := client.ClaimRewardsIfAbove(context.Background(), tt.threshold)
+			if tt.wantErr != errors.Is(err, ErrBelowClaimThreshold) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("ClaimRewardsIfAbove failed: %v", err)
+			}
+
+			wantSent := 1
+			if tt.wantErr {
+				wantSent = 0
+			}
+			if sent := len(backend.SentTransactions()); sent != wantSent {
+				t.Errorf("expected %d sent transactions, got %d", wantSent, sent)
+			}
+		})
+	}
+}
+
+func TestClaimRewardsIfProfitable(t *testing.T) {
+	// 0.5 pending reward tokens priced at 0.01 ETH are worth 0.005 ETH; claiming costs
+	// 21000 gas at 1 gwei = 0.000021 ETH, or 42 bps of the reward value
+	price := big.NewInt(1e16)
+
+	tests := []struct {
+		name      string
+		maxGasBps uint64
+		wantErr   bool
+	}{
+		{name: "gas too expensive", maxGasBps: 41, wantErr: true},
+		{name: "break even", maxGasBps: 42},
+		{name: "cheap gas", maxGasBps: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t)
+			WithRewardTokenDecimals(18)(client)
+
+			_, err := client.ClaimRewardsIfProfitable(context.Background(), tt.maxGasBps, price)
+			if tt.wantErr {
+				if !errors.Is(err, ErrBelowClaimThreshold) {
+					t.Fatalf("expected ErrBelowClaimThreshold, got %v", err)
+				}
+				if sent := len(backend.SentTransactions()); sent != 0 {
+					t.Errorf("expected no claim, got %d transactions", sent)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ClaimRewardsIfProfitable failed: %v", err)
+			}
+		})
+	}
+}
+
+const accessControlABI = `
+	{"type":"function","name":"owner","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"hasRole","stateMutability":"view","inputs":[{"name":"role","type":"bytes32"},{"name":"account","type":"address"}],"outputs":[{"name":"","type":"bool"}]}`
+
+func TestGetContractOwner(t *testing.T) {
+	client, backend := newTestClient(t, accessControlABI)
+	owner := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	setCallResult(t, client, backend, "owner", owner)
+
+	got, err := client.GetContractOwner(context.Background())
+	if err != nil {
+		t.Fatalf("GetContractOwner failed: %v", err)
+	}
+	if got != owner {
+		t.Errorf("expected owner %s, got %s", owner.Hex(), got.Hex())
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	client, backend := newTestClient(t, accessControlABI)
+	pauser := crypto.Keccak256Hash([]byte("PAUSER_ROLE"))
+	admin := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+
+	setCallResult(t, client, backend, "hasRole", false)
+	if err := backend.SetCallResultFor(client.contractABI.Methods["hasRole"], []interface{}{pauser, admin}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		account common.Address
+		want    bool
+	}{{admin, true}, {client.from(), false}} {
+		got, err := client.HasRole(context.Background(), pauser, tt.account)
+		if err != nil {
+			t.Fatalf("HasRole failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("HasRole(PAUSER_ROLE, %s) = %v, want %v", tt.account.Hex(), got, tt.want)
+		}
+	}
+}
+
+func TestAccessControlUnsupported(t *testing.T) {
+	client, _ := newTestClient(t)
+	if _, err := client.GetContractOwner(context.Background()); !errors.Is(err, ErrUnsupportedMethod) || !strings.Contains(err.Error(), "Ownable") {
+		t.Errorf("expected a not-Ownable ErrUnsupportedMethod, got %v", err)
+	}
+	if _, err := client.HasRole(context.Background(), [32]byte{}, client.from()); !errors.Is(err, ErrUnsupportedMethod) || !strings.Contains(err.Error(), "AccessControl") {
+		t.Errorf("expected a no-AccessControl ErrUnsupportedMethod, got %v", err)
+	}
+}
+
+const protocolFeesABI = `
+	{"type":"function","name":"pendingFees","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"collectFees","inputs":[],"outputs":[]}`
+
+func TestGetAccruedFees(t *testing.T) {
+	client, backend := newTestClient(t, protocolFeesABI)
+	setCallResult(t, client, backend, "pendingFees", tokens(3, 18))
+
+	fees, err := client.GetAccruedFees(context.Background())
+	if err != nil {
+		t.Fatalf("GetAccruedFees failed: %v", err)
+	}
+	if fees.Cmp(tokens(3, 18)) != 0 {
+		t.Errorf("expected 3 tokens of fees, got %s", fees)
+	}
+
+	client, _ = newTestClient(t)
+	if _, err := client.GetAccruedFees(context.Background()); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("expected ErrUnsupportedMethod without a fee view, got %v", err)
+	}
+	if _, err := client.CollectFees(context.Background()); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("expected ErrUnsupportedMethod without a collect method, got %v", err)
+	}
+}
+
+func TestCollectFeesRequiresOwner(t *testing.T) {
+	ownerABI := `{"type":"function","name":"owner","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]}`
+	stranger := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+
+	for _, tt := range []struct {
+		name    string
+		owner   func(client *YieldFarmingClient) common.Address
+		wantErr error
+	}{
+		{name: "owner", owner: func(client *YieldFarmingClient) common.Address { return client.from() }},
+		{name: "not owner", owner: func(*YieldFarmingClient) common.Address { return stranger }, wantErr: ErrUnauthorized},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t, protocolFeesABI, ownerABI)
+			setCallResult(t, client, backend, "owner", tt.owner(client))
+
+			_, err := client.CollectFees(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if wantSent := tt.wantErr == nil; (len(backend.SentTransactions()) == 1) != wantSent {
+				t.Errorf("expected sent=%v, got %d transactions", wantSent, len(backend.SentTransactions()))
+			}
+		})
+	}
+}
+
+func TestCollectFeesRequiresRole(t *testing.T) {
+	hasRoleABI := `{"type":"function","name":"hasRole","stateMutability":"view","inputs":[{"name":"role","type":"bytes32"},{"name":"account","type":"address"}],"outputs":[{"name":"","type":"bool"}]}`
+
+	client, backend := newTestClient(t, protocolFeesABI, hasRoleABI)
+	setCallResult(t, client, backend, "hasRole", false)
+	if _, err := client.CollectFees(context.Background()); !errors.Is(err, ErrUnauthorized) || !strings.Contains(err.Error(), "FEE_MANAGER_ROLE") {
+		t.Fatalf("expected ErrUnauthorized naming the roles, got %v", err)
+	}
+
+	feeManager := crypto.Keccak256Hash([]byte("FEE_MANAGER_ROLE"))
+	if err := backend.SetCallResultFor(client.contractABI.Methods["hasRole"], []interface{}{feeManager, client.from()}, true); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := client.CollectFees(context.Background())
+	if err != nil {
+		t.Fatalf("CollectFees failed: %v", err)
+	}
+	if want := client.contractABI.Methods["collectFees"].ID; !bytes.Equal(tx.Data(), want) {
+		t.Errorf("expected collectFees call data %x, got %x", want, tx.Data())
+	}
+}
+
+const pausableABI = `{"type":"function","name":"paused","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"bool"}]}`
+
+func TestPausedPoolRejectsDepositsAndWithdrawals(t *testing.T) {
+	tests := []struct {
+		name    string
+		paused  bool
+		wantErr error
+	}{
+		{name: "paused", paused: true, wantErr: ErrPoolPaused},
+		{name: "active", paused: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t, pausableABI)
+			setCallResult(t, client, backend, "paused", tt.paused)
+			ctx := context.Background()
+
+			paused, err := client.IsPaused(ctx)
+			if err != nil {
+				t.Fatalf("IsPaused failed: %v", err)
+			}
+			if paused != tt.paused {
+				t.Errorf("expected paused=%v, got %v", tt.paused, paused)
+			}
+
+			if _, err := client.Deposit(ctx, big.NewInt(1e18)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Deposit: expected error %v, got %v", tt.wantErr, err)
+			}
+			if _, err := client.Withdraw(ctx, big.NewInt(1e18)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Withdraw: expected error %v, got %v", tt.wantErr, err)
+			}
+
+			wantSent := 2
+			if tt.paused {
+				wantSent = 0
+			}
+			if sent := len(backend.SentTransactions()); sent != wantSent {
+				t.Errorf("expected %d sent transactions, got %d", wantSent, sent)
+			}
+		})
+	}
+}
+
+func TestPausedStatusIsCachedUntilTTL(t *testing.T) {
+	client, backend := newTestClient(t, pausableABI)
+	setCallResult(t, client, backend, "paused", false)
+
+	now := time.Unix(1700000000, 0)
+	client.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.IsPaused(ctx); err != nil {
+			t.Fatalf("IsPaused %d failed: %v", i, err)
+		}
+	}
+	if calls := len(backend.Calls()); calls != 1 {
+		t.Fatalf("expected paused to be read once, got %d calls", calls)
+	}
+
+	setCallResult(t, client, backend, "paused", true)
+	now = now.Add(defaultPausedTTL)
+	paused, err := client.IsPaused(ctx)
+	if err != nil {
+		t.Fatalf("IsPaused after TTL failed: %v", err)
+	}
+	if !paused {
+		t.Error("expected the refreshed status to report paused")
+	}
+}
+
+func TestPauseCheckCanBeDisabled(t *testing.T) {
+	client, backend := newTestClient(t, pausableABI)
+	WithoutPauseCheck()(client)
+
+	if _, err := client.Deposit(context.Background(), big.NewInt(1e18)); err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	if calls := len(backend.Calls()); calls != 0 {
+		t.Errorf("expected no paused reads when disabled, got %d", calls)
+	}
+}
+
+func TestErrorsMatchSentinels(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		run     func(t *testing.T) error
+		wantErr error
+	}{
+		{
+			name: "read-only client",
+			run: func(t *testing.T) error {
+				client, backend := newTestClient(t)
+				readOnly, err := NewYieldFarmingClientWithBackend(backend, testContractAddress, "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				readOnly.contractABI = client.contractABI
+				_, err = readOnly.ClaimRewards(ctx)
+				return err
+			},
+			wantErr: ErrReadOnly,
+		},
+		{
+			name: "invalid amount",
+			run: func(t *testing.T) error {
+				client, _ := newTestClient(t)
+				_, err := client.DepositWithMinShares(ctx, big.NewInt(0), big.NewInt(0))
+				return err
+			},
+			wantErr: ErrInvalidAmount,
+		},
+		{
+			name: "insufficient staked balance",
+			run: func(t *testing.T) error {
+				client, _ := newTestClient(t)
+				_, err := client.Withdraw(ctx, tokens(11, 18))
+				return err
+			},
+			wantErr: ErrInsufficientBalance,
+		},
+		{
+			name: "pool paused",
+			run: func(t *testing.T) error {
+				client, backend := newTestClient(t, pausableABI)
+				setCallResult(t, client, backend, "paused", true)
+				_, err := client.Deposit(ctx, big.NewInt(1))
+				return err
+			},
+			wantErr: ErrPoolPaused,
+		},
+		{
+			name: "gas price too high",
+			run: func(t *testing.T) error {
+				client, _ := newTestClient(t)
+				WithMaxGasPrice(big.NewInt(1))(client)
+				_, err := client.ClaimRewards(ctx)
+				return err
+			},
+			wantErr: ErrGasPriceTooHigh,
+		},
+		{
+			name: "reverted transaction",
+			run: func(t *testing.T) error {
+				client, backend := newTestClient(t)
+				tx, err := client.ClaimRewards(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				backend.SetReceipt(tx.Hash(), &types.Receipt{Status: types.ReceiptStatusFailed, TxHash: tx.Hash(), BlockNumber: big.NewInt(1)})
+				_, err = client.WaitForTransaction(ctx, tx)
+				return err
+			},
+			wantErr: ErrReverted,
+		},
+		{
+			name: "unsupported method",
+			run: func(t *testing.T) error {
+				client, _ := newTestClient(t)
+				_, err := client.Exit(ctx, big.NewInt(1))
+				return err
+			},
+			wantErr: ErrUnsupportedMethod,
+		},
+		{
+			name: "token not configured",
+			run: func(t *testing.T) error {
+				client, _ := newTestClient(t)
+				_, err := client.RewardTokenDecimals(ctx)
+				return err
+			},
+			wantErr: ErrTokenNotConfigured,
+		},
+		{
+			name: "still locked",
+			run: func(t *testing.T) error {
+				client, backend := newTestClient(t, lockupABI)
+				setCallResult(t, client, backend, "unlockTime", big.NewInt(int64(backend.Head.Time)+3600))
+				_, err := client.Withdraw(ctx, big.NewInt(1))
+				return err
+			},
+			wantErr: ErrStillLocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run(t)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected errors.Is(err, %v), got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDepositChecksAllowanceBeforeEstimating(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowance     *big.Int
+		wantShortfall *big.Int
+	}{
+		{name: "zero allowance", allowance: big.NewInt(0), wantShortfall: tokens(1, 18)},
+		{name: "partial allowance", allowance: big.NewInt(4e17), wantShortfall: big.NewInt(6e17)},
+		{name: "sufficient allowance", allowance: tokens(1, 18)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t)
+			WithStakingToken(common.HexToAddress("0x00000000000000000000000000000000000000aa"))(client)
+			if err := backend.SetCallResult(erc20ABI.Methods["allowance"], tt.allowance); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := client.Deposit(context.Background(), tokens(1, 18))
+			if tt.wantShortfall == nil {
+				if err != nil {
+					t.Fatalf("Deposit failed: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrInsufficientAllowance) {
+				t.Fatalf("expected ErrInsufficientAllowance, got %v", err)
+			}
+			var allowanceErr *AllowanceError
+			if !errors.As(err, &allowanceErr) {
+				t.Fatalf("expected an AllowanceError, got %T", err)
+			}
+			if allowanceErr.Shortfall().Cmp(tt.wantShortfall) != 0 {
+				t.Errorf("expected shortfall %s, got %s", tt.wantShortfall, allowanceErr.Shortfall())
+			}
+			if sent := len(backend.SentTransactions()); sent != 0 {
+				t.Errorf("expected nothing to be sent, got %d", sent)
+			}
+		})
+	}
+}
+
+func TestPoolInfoJSON(t *testing.T) {
+	decimals := 18
+	info := &PoolInfo{
+		TotalValueLocked: tokens(1000, 18),
+		CurrentAPY:       big.NewInt(1500),
+		RewardRate:       big.NewInt(15e17),
+		LastUpdateTime:   big.NewInt(1700000000),
+	}
+
+	data, err := info.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	want := `{"totalValueLocked":"1000000000000000000000","currentAPY":"1500","rewardRate":"1500000000000000000","lastUpdateTime":"1700000000"}`
+	if string(data) != want {
+		t.Errorf("unexpected JSON:\n got %s\nwant %s", data, want)
+	}
+
+	info.TokenDecimals = &decimals
+	data, err = info.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	want = `{"totalValueLocked":"1000000000000000000000","currentAPY":"1500","rewardRate":"1500000000000000000","lastUpdateTime":"1700000000","tokenDecimals":18,"totalValueLockedFormatted":"1000","rewardRateFormatted":"1.5"}`
+	if string(data) != want {
+		t.Errorf("unexpected formatted JSON:\n got %s\nwant %s", data, want)
+	}
+
+	var decoded PoolInfo
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.TotalValueLocked.Cmp(info.TotalValueLocked) != 0 || decoded.CurrentAPY.Cmp(info.CurrentAPY) != 0 ||
+		decoded.RewardRate.Cmp(info.RewardRate) != 0 || decoded.LastUpdateTime.Cmp(info.LastUpdateTime) != 0 {
+		t.Errorf("round trip mismatch: %+v", decoded)
+	}
+	if decoded.TokenDecimals == nil || *decoded.TokenDecimals != decimals {
+		t.Errorf("expected token decimals to round trip, got %v", decoded.TokenDecimals)
+	}
+}
+
+const rebasingTokenABI = `[
+	{"type":"function","name":"getPooledEthByShares","stateMutability":"view","inputs":[{"name":"shares","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"convertToAssets","stateMutability":"view","inputs":[{"name":"shares","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]}]`
+
+func TestGetUserPositionRebasingStakingToken(t *testing.T) {
+	tokenABI, err := abi.JSON(strings.NewReader(rebasingTokenABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{"", "convertToAssets"} {
+		t.Run("method "+method, func(t *testing.T) {
+			client, backend := newTestClient(t, userInfoABI, pendingRewardsABI)
+			WithStakingToken(common.HexToAddress("0x00000000000000000000000000000000000000aa"))(client)
+			WithRebasingStakingToken(method)(client)
+			setCallResult(t, client, backend, "userInfo", tokens(10, 18), big.NewInt(0))
+			setCallResult(t, client, backend, "pendingRewards", big.NewInt(0))
+
+			conversion := tokenABI.Methods["getPooledEthByShares"]
+			if method != "" {
+				conversion = tokenABI.Methods[method]
+			}
+
+			// A rebase raises the underlying value of the same 10 shares by 5%
+			for _, underlying := range []*big.Int{tokens(10, 18), new(big.Int).Div(tokens(1050, 18), big.NewInt(100))} {
+				if err := backend.SetCallResult(conversion, underlying); err != nil {
+					t.Fatal(err)
+				}
+
+				position, err := client.GetUserPosition(context.Background(), client.from())
+				if err != nil {
+					t.Fatalf("GetUserPosition failed: %v", err)
+				}
+				if position.StakedShares.Cmp(tokens(10, 18)) != 0 {
+					t.Errorf("expected 10 staked shares, got %s", position.StakedShares)
+				}
+				if position.StakedBalance.Cmp(underlying) != 0 {
+					t.Errorf("expected staked balance %s, got %s", underlying, position.StakedBalance)
+				}
+			}
+		})
+	}
+}
+
+func TestUserPositionJSON(t *testing.T) {
+	decimals := 6
+	position := UserPosition{
+		StakedBalance:  big.NewInt(2500000),
+		PendingRewards: big.NewInt(1),
+		LastClaimTime:  big.NewInt(1700000000),
+		TokenDecimals:  &decimals,
+	}
+
+	data, err := json.Marshal(position)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `{"stakedBalance":"2500000","pendingRewards":"1","lastClaimTime":"1700000000","rewardDebt":null,"tokenDecimals":6,"stakedBalanceFormatted":"2.5","pendingRewardsFormatted":"0.000001"}`
+	if string(data) != want {
+		t.Errorf("unexpected JSON:\n got %s\nwant %s", data, want)
+	}
+
+	var decoded UserPosition
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.StakedBalance.Cmp(position.StakedBalance) != 0 || decoded.PendingRewards.Cmp(position.PendingRewards) != 0 ||
+		decoded.LastClaimTime.Cmp(position.LastClaimTime) != 0 || decoded.RewardDebt != nil {
+		t.Errorf("round trip mismatch: %+v", decoded)
+	}
+
+	if err := json.Unmarshal([]byte(`{"stakedBalance":"1e18"}`), &decoded); err == nil {
+		t.Error("expected scientific notation to be rejected")
+	}
+}
+
+func TestUserPositionJSONReadAt(t *testing.T) {
+	position := UserPosition{StakedBalance: big.NewInt(1), ReadAt: time.Unix(1700000000, 0).UTC()}
+
+	data, err := json.Marshal(position)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"readAt":"2023-11-14T22:13:20Z"`) {
+		t.Errorf("expected readAt in %s", data)
+	}
+
+	var decoded UserPosition
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !decoded.ReadAt.Equal(position.ReadAt) {
+		t.Errorf("expected ReadAt %s, got %s", position.ReadAt, decoded.ReadAt)
+	}
+}
+
+func TestDiffPositions(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		name        string
+		before      *UserPosition
+		after       *UserPosition
+		wantStaked  *big.Int
+		wantPending *big.Int
+		wantElapsed time.Duration
+	}{
+		{
+			name:        "rewards accrued",
+			before:      &UserPosition{StakedBalance: tokens(10, 18), PendingRewards: tokens(1, 18), ReadAt: start},
+			after:       &UserPosition{StakedBalance: tokens(10, 18), PendingRewards: tokens(3, 18), ReadAt: start.Add(time.Hour)},
+			wantStaked:  big.NewInt(0),
+			wantPending: tokens(2, 18),
+			wantElapsed: time.Hour,
+		},
+		{
+			name:        "claimed and withdrew",
+			before:      &UserPosition{StakedBalance: tokens(10, 18), PendingRewards: tokens(3, 18), ReadAt: start},
+			after:       &UserPosition{StakedBalance: tokens(4, 18), PendingRewards: big.NewInt(0), ReadAt: start.Add(90 * time.Second)},
+			wantStaked:  tokens(-6, 18),
+			wantPending: tokens(-3, 18),
+			wantElapsed: 90 * time.Second,
+		},
+		{
+			name:        "first deposit without read times",
+			before:      nil,
+			after:       &UserPosition{StakedBalance: tokens(5, 18)},
+			wantStaked:  tokens(5, 18),
+			wantPending: big.NewInt(0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta := DiffPositions(tt.before, tt.after)
+			if delta.StakedBalance.Cmp(tt.wantStaked) != 0 {
+				t.Errorf("staked delta = %s, want %s", delta.StakedBalance, tt.wantStaked)
+			}
+			if delta.PendingRewards.Cmp(tt.wantPending) != 0 {
+				t.Errorf("pending delta = %s, want %s", delta.PendingRewards, tt.wantPending)
+			}
+			if delta.Elapsed != tt.wantElapsed {
+				t.Errorf("elapsed = %s, want %s", delta.Elapsed, tt.wantElapsed)
+			}
+		})
+	}
+}
+
+func TestGetUserPositionSetsReadAt(t *testing.T) {
+	client, _ := newTestClient(t)
+	now := time.Unix(1700000000, 0)
+	client.now = func() time.Time { return now }
+
+	position, err := client.GetUserPosition(context.Background(), client.from())
+	if err != nil {
+		t.Fatalf("GetUserPosition failed: %v", err)
+	}
+	if !position.ReadAt.Equal(now) {
+		t.Errorf("expected ReadAt %s, got %s", now, position.ReadAt)
+	}
+}
+
+// newPositionCacheClient returns a client with an enabled position cache over a pool
+// emitting Deposit, Withdraw and RewardPaid events, and a controllable clock
+func newPositionCacheClient(t *testing.T) (*YieldFarmingClient, *ethtest.Backend, *time.Time) {
+	t.Helper()
+
+	client, backend := newTestClient(t, userInfoABI, pendingRewardsABI, poolEventsABI)
+	setCallResult(t, client, backend, "userInfo", tokens(10, 18), big.NewInt(0))
+	setCallResult(t, client, backend, "pendingRewards", big.NewInt(0))
+	now := time.Unix(1700000000, 0)
+	client.now = func() time.Time { return now }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := client.EnablePositionCache(ctx); err != nil {
+		t.Fatalf("EnablePositionCache failed: %v", err)
+	}
+	return client, backend, &now
+}
+
+// positionReads returns how many contract calls GetUserPosition has made
+func positionReads(t *testing.T, client *YieldFarmingClient, user common.Address) int {
+	t.Helper()
+
+	if _, err := client.GetUserPosition(context.Background(), user); err != nil {
+		t.Fatalf("GetUserPosition failed: %v", err)
+	}
+	return client.client.(*ethtest.Backend).MethodCalls("CallContract")
+}
+
+// waitForEviction waits for the cache subscription to evict user's position
+func waitForEviction(t *testing.T, client *YieldFarmingClient, user common.Address) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		client.cacheMu.RLock()
+		_, cached := client.positionCache[user]
+		client.cacheMu.RUnlock()
+		if !cached {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("position of %s was not evicted", user.Hex())
+}
+
+func TestPositionCacheServesHitsWithinTTL(t *testing.T) {
+	client, _, now := newPositionCacheClient(t)
+	user := client.from()
+
+	first := positionReads(t, client, user)
+	if got := positionReads(t, client, user); got != first {
+		t.Errorf("expected a cache hit, got %d contract calls after %d", got, first)
+	}
+
+	*now = now.Add(defaultPositionCacheTTL)
+	if got := positionReads(t, client, user); got <= first {
+		t.Errorf("expected the position to be re-read after the TTL, got %d contract calls", got)
+	}
+}
+
+func TestPositionCacheInvalidatedByEvents(t *testing.T) {
+	for _, name := range []string{"Deposit", "Withdraw", "RewardPaid"} {
+		t.Run(name, func(t *testing.T) {
+			client, backend, _ := newPositionCacheClient(t)
+			user := client.from()
+			other := common.HexToAddress("0x00000000000000000000000000000000000000f2")
+
+			positionReads(t, client, user)
+			reads := positionReads(t, client, other)
+
+			event := client.contractABI.Events[name]
+			data, err := event.Inputs.NonIndexed().Pack(big.NewInt(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			backend.EmitLog(types.Log{
+				Address: testContractAddress,
+				Topics:  []common.Hash{event.ID, common.BytesToHash(user.Bytes())},
+				Data:    data,
+			})
+			waitForEviction(t, client, user)
+
+			if got := positionReads(t, client, other); got != reads {
+				t.Errorf("expected %s's position to stay cached, got %d contract calls after %d", other.Hex(), got, reads)
+			}
+			if got := positionReads(t, client, user); got <= reads {
+				t.Errorf("expected the position to be re-read after a %s event", name)
+			}
+		})
+	}
+}
+
+func TestPositionCacheDisabledOnSubscriptionError(t *testing.T) {
+	client, backend, _ := newPositionCacheClient(t)
+	user := client.from()
+	positionReads(t, client, user)
+
+	backend.FailSubscriptions(errors.New("connection lost"))
+	waitForEviction(t, client, user)
+
+	reads := positionReads(t, client, user)
+	if got := positionReads(t, client, user); got <= reads {
+		t.Errorf("expected reads to bypass the dropped cache, got %d contract calls after %d", got, reads)
+	}
+}
+
+func TestPositionCacheThroughWrappedBackend(t *testing.T) {
+	backend := ethtest.NewBackend()
+	backend.SetCode(testContractAddress, testPoolCode)
+	client, err := NewYieldFarmingClientWithBackend(backend, testContractAddress, "", withRPCURL("ws://node.test"),
+		WithRateLimit(RateLimit{RPS: 1000, Burst: 100}))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	limited, ok := client.client.(*rateLimitedBackend)
+	if !ok {
+		t.Fatalf("expected a rate-limited backend, got %T", client.client)
+	}
+	if _, ok := limited.backend.(*reconnectingBackend); !ok {
+		t.Fatalf("expected a reconnecting backend under the rate limiter, got %T", limited.backend)
+	}
+	client.contractABI, err = abi.JSON(strings.NewReader("[" + strings.Join([]string{testPoolABI, userInfoABI, pendingRewardsABI, poolEventsABI}, ",") + "]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setCallResult(t, client, backend, "userInfo", tokens(10, 18), big.NewInt(0))
+	setCallResult(t, client, backend, "pendingRewards", big.NewInt(0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := client.EnablePositionCache(ctx); err != nil {
+		t.Fatalf("EnablePositionCache failed: %v", err)
+	}
+
+	user := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	if _, err := client.GetUserPosition(context.Background(), user); err != nil {
+		t.Fatalf("GetUserPosition failed: %v", err)
+	}
+	client.cacheMu.RLock()
+	_, cached := client.positionCache[user]
+	client.cacheMu.RUnlock()
+	if !cached {
+		t.Fatal("expected the position to be cached")
+	}
+
+	event := client.contractABI.Events["Deposit"]
+	data, err := event.Inputs.NonIndexed().Pack(big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.EmitLog(types.Log{
+		Address: testContractAddress,
+		Topics:  []common.Hash{event.ID, common.BytesToHash(user.Bytes())},
+		Data:    data,
+	})
+	waitForEviction(t, client, user)
+}
+
+func TestEnablePositionCacheRequiresEvents(t *testing.T) {
+	client, _ := newTestClient(t)
+	if err := client.EnablePositionCache(context.Background()); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Fatalf("expected ErrUnsupportedMethod, got %v", err)
+	}
+}
+
+func TestLegacySignerSignsHomesteadTransactions(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := ethtest.NewBackend()
+	backend.ChainIDValue = big.NewInt(0)
+
+	client, err := NewYieldFarmingClientWithBackend(backend, testContractAddress, common.Bytes2Hex(crypto.FromECDSA(key)),
+		WithChainID(big.NewInt(0)), WithLegacySigner())
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	testClient, _ := newTestClient(t)
+	client.contractABI = testClient.contractABI
+
+	tx, err := client.ClaimRewards(context.Background())
+	if err != nil {
+		t.Fatalf("ClaimRewards failed: %v", err)
+	}
+	if tx.Protected() {
+		t.Error("expected an unprotected pre-EIP155 transaction")
+	}
+	sender, err := types.Sender(types.HomesteadSigner{}, tx)
+	if err != nil {
+		t.Fatalf("failed to recover sender: %v", err)
+	}
+	if want := crypto.PubkeyToAddress(key.PublicKey); sender != want {
+		t.Errorf("expected sender %s, got %s", want.Hex(), sender.Hex())
+	}
+}
+
+func TestSignerConfigurationIsValidated(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyHex := common.Bytes2Hex(crypto.FromECDSA(key))
+
+	tests := []struct {
+		name    string
+		opts    []ClientOption
+		wantErr bool
+	}{
+		{name: "default mainnet", opts: nil},
+		{name: "custom chain", opts: []ClientOption{WithChainID(big.NewInt(1337))}},
+		{name: "legacy with nil chain", opts: []ClientOption{WithChainID(nil), WithLegacySigner()}},
+		{name: "legacy with zero chain", opts: []ClientOption{WithChainID(big.NewInt(0)), WithLegacySigner()}},
+		{name: "legacy with replay-protected chain", opts: []ClientOption{WithLegacySigner()}, wantErr: true},
+		{name: "zero chain without legacy", opts: []ClientOption{WithChainID(big.NewInt(0))}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewYieldFarmingClientWithBackend(ethtest.NewBackend(), testContractAddress, keyHex, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// newReconnectingTestClient returns a client that believes it was dialled from an RPC URL,
+// re-dialling through dial without sleeping between attempts
+func newReconnectingTestClient(t *testing.T, backend *ethtest.Backend, dial func(ctx context.Context, rpcURL string) (EthBackend, error)) (*YieldFarmingClient, *[]time.Duration) {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewYieldFarmingClientWithBackend(backend, testContractAddress, common.Bytes2Hex(crypto.FromECDSA(key)),
+		withRPCURL("ws://node.test"), withDialer(dial), WithReconnect(3, time.Second))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	var sleeps []time.Duration
+	client.client.(*reconnectingBackend).sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return client, &sleeps
+}
+
+func TestClientReconnectsAfterConnectionDrop(t *testing.T) {
+	dropped := ethtest.NewBackend()
+	recovered := ethtest.NewBackend()
+	recovered.Head.Number = big.NewInt(42)
+
+	var dials []string
+	client, sleeps := newReconnectingTestClient(t, dropped, func(ctx context.Context, rpcURL string) (EthBackend, error) {
+		dials = append(dials, rpcURL)
+		if len(dials) == 1 {
+			return nil, syscall.ECONNREFUSED
+		}
+		return recovered, nil
+	})
+	ctx := context.Background()
+
+	if _, err := client.GetLatestBlock(ctx); err != nil {
+		t.Fatalf("GetLatestBlock before drop failed: %v", err)
+	}
+
+	dropped.Err = &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	number, err := client.GetLatestBlock(ctx)
+	if err != nil {
+		t.Fatalf("GetLatestBlock after drop failed: %v", err)
+	}
+	if number != 42 {
+		t.Errorf("expected block 42 from the new connection, got %d", number)
+	}
+	if len(dials) != 2 || dials[0] != "ws://node.test" {
+		t.Errorf("expected two dials of the stored URL, got %v", dials)
+	}
+	if want := []time.Duration{time.Second, 2 * time.Second}; len(*sleeps) != 2 || (*sleeps)[0] != want[0] || (*sleeps)[1] != want[1] {
+		t.Errorf("expected backoff %v, got %v", want, *sleeps)
+	}
+
+	// Later calls go straight to the recovered connection
+	if _, err := client.GetLatestBlock(ctx); err != nil {
+		t.Fatalf("GetLatestBlock after recovery failed: %v", err)
+	}
+	if len(dials) != 2 {
+		t.Errorf("expected no further dials, got %d", len(dials))
+	}
+}
+
+func TestClientDoesNotReconnectOnRequestErrors(t *testing.T) {
+	backend := ethtest.NewBackend()
+	backend.Err = errors.New("execution reverted")
+
+	dialled := false
+	client, _ := newReconnectingTestClient(t, backend, func(ctx context.Context, rpcURL string) (EthBackend, error) {
+		dialled = true
+		return ethtest.NewBackend(), nil
+	})
+
+	if _, err := client.GetLatestBlock(context.Background()); err == nil {
+		t.Fatal("expected the request error to be returned")
+	}
+	if dialled {
+		t.Error("expected no reconnect for a non-connection error")
+	}
+}
+
+func TestClientGivesUpAfterReconnectAttempts(t *testing.T) {
+	backend := ethtest.NewBackend()
+	backend.Err = io.EOF
+
+	client, sleeps := newReconnectingTestClient(t, backend, func(ctx context.Context, rpcURL string) (EthBackend, error) {
+		return nil, syscall.ECONNREFUSED
+	})
+
+	_, err := client.GetLatestBlock(context.Background())
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("expected the original connection error, got %v", err)
+	}
+	if len(*sleeps) != 3 {
+		t.Errorf("expected 3 reconnect attempts, got %d", len(*sleeps))
+	}
+}
+
+// newMultiRPCTestClient creates a client over one fake backend per URL, dialled through
+// dial, without sleeping between reconnect attempts
+func newMultiRPCTestClient(t *testing.T, urls []string, dial func(ctx context.Context, rpcURL string) (EthBackend, error)) (*YieldFarmingClient, *[]time.Duration, error) {
+	t.Helper()
+
+	client, err := newYieldFarmingClientMultiRPC(context.Background(), urls, dial, testContractAddress, "", WithReconnect(2, time.Second))
+	if err != nil {
+		return nil, nil, err
+	}
+	var sleeps []time.Duration
+	client.endpoints.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return client, &sleeps, nil
+}
+
+func TestMultiRPCSkipsEndpointFailingToDial(t *testing.T) {
+	second := ethtest.NewBackend()
+	second.Head.Number = big.NewInt(42)
+
+	client, _, err := newMultiRPCTestClient(t, []string{"ws://a.test", "ws://b.test"}, func(ctx context.Context, rpcURL string) (EthBackend, error) {
+		if rpcURL == "ws://a.test" {
+			return nil, syscall.ECONNREFUSED
+		}
+		return second, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	number, err := client.GetLatestBlock(context.Background())
+	if err != nil || number != 42 {
+		t.Fatalf("expected block 42 from the second endpoint, got %d, %v", number, err)
+	}
+	if endpoints := client.RPCEndpoints(); len(endpoints) != 2 || endpoints[0].Active || !endpoints[1].Active {
+		t.Errorf("expected the second endpoint to be active, got %+v", endpoints)
+	}
+}
+
+func TestMultiRPCFailsOverOnError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "connection error", err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}},
+		{name: "transient HTTP error", err: rpc.HTTPError{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, second := ethtest.NewBackend(), ethtest.NewBackend()
+			first.Err = tt.err
+			second.Head.Number = big.NewInt(42)
+			backends := map[string]*ethtest.Backend{"ws://a.test": first, "ws://b.test": second}
+
+			var dials []string
+			client, sleeps, err := newMultiRPCTestClient(t, []string{"ws://a.test", "ws://b.test"}, func(ctx context.Context, rpcURL string) (EthBackend, error) {
+				dials = append(dials, rpcURL)
+				return backends[rpcURL], nil
+			})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			number, err := client.GetLatestBlock(context.Background())
+			if err != nil || number != 42 {
+				t.Fatalf("expected block 42 after failover, got %d, %v", number, err)
+			}
+			if len(dials) != 2 || dials[1] != "ws://b.test" {
+				t.Errorf("expected a failover dial of the second endpoint, got %v", dials)
+			}
+			if len(*sleeps) != 0 {
+				t.Errorf("expected failover without backoff, got %v", *sleeps)
+			}
+
+			endpoints := client.RPCEndpoints()
+			if endpoints[0].Active || endpoints[0].ConsecutiveFailures != 1 || endpoints[0].LastError == nil {
+				t.Errorf("expected the first endpoint to be marked failed, got %+v", endpoints[0])
+			}
+			if !endpoints[1].Active || endpoints[1].ConsecutiveFailures != 0 {
+				t.Errorf("expected the second endpoint to be active and healthy, got %+v", endpoints[1])
+			}
+		})
+	}
+}
+
+func TestMultiRPCBacksOffWhenEveryEndpointFails(t *testing.T) {
+	first := ethtest.NewBackend()
+	first.Err = io.EOF
+
+	client, sleeps, err := newMultiRPCTestClient(t, []string{"ws://a.test", "ws://b.test"}, func(ctx context.Context, rpcURL string) (EthBackend, error) {
+		if first != nil {
+			backend := first
+			first = nil
+			return backend, nil
+		}
+		return nil, syscall.ECONNREFUSED
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err := client.GetLatestBlock(context.Background()); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected the original connection error, got %v", err)
+	}
+	if want := []time.Duration{time.Second, 2 * time.Second}; len(*sleeps) != 2 || (*sleeps)[0] != want[0] || (*sleeps)[1] != want[1] {
+		t.Errorf("expected backoff %v, got %v", want, *sleeps)
+	}
+	if endpoints := client.RPCEndpoints(); endpoints[1].ConsecutiveFailures != 2 {
+		t.Errorf("expected the second endpoint to record its failed dials, got %+v", endpoints)
+	}
+}
+
+func TestMultiRPCRequiresReachableEndpoint(t *testing.T) {
+	_, _, err := newMultiRPCTestClient(t, []string{"ws://a.test", "ws://b.test"}, func(ctx context.Context, rpcURL string) (EthBackend, error) {
+		return nil, syscall.ECONNREFUSED
+	})
+	if err == nil || !strings.Contains(err.Error(), "ws://a.test") || !strings.Contains(err.Error(), "ws://b.test") {
+		t.Errorf("expected an error naming both endpoints, got %v", err)
+	}
+	if _, _, err := newMultiRPCTestClient(t, nil, nil); err == nil {
+		t.Error("expected an error without RPC URLs")
+	}
+}
+
+func TestGetLatestBlockUsesBlockNumber(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.Head.Number = big.NewInt(1234)
+	backend.Head.Time = 1700000000
+	backend.Head.BaseFee = big.NewInt(7)
+	ctx := context.Background()
+
+	number, err := client.GetLatestBlock(ctx)
+	if err != nil {
+		t.Fatalf("GetLatestBlock failed: %v", err)
+	}
+	if number != 1234 {
+		t.Errorf("expected block 1234, got %d", number)
+	}
+	if calls := backend.MethodCalls("BlockNumber"); calls != 1 {
+		t.Errorf("expected one BlockNumber call, got %d", calls)
+	}
+
+	header, err := client.GetLatestHeader(ctx)
+	if err != nil {
+		t.Fatalf("GetLatestHeader failed: %v", err)
+	}
+	if header.Number.Uint64() != number || header.Time != 1700000000 || header.BaseFee.Int64() != 7 {
+		t.Errorf("unexpected header: number %s, time %d, base fee %s", header.Number, header.Time, header.BaseFee)
+	}
+
+	if calls := backend.MethodCalls("BlockByNumber"); calls != 0 {
+		t.Errorf("expected no full block fetches, got %d", calls)
+	}
+}
+
+func TestGasLimitOverrides(t *testing.T) {
+	tests := []struct {
+		name          string
+		limits        map[string]uint64
+		wantGas       uint64
+		wantEstimates int
+	}{
+		{name: "override for deposit", limits: map[string]uint64{"deposit": 150000}, wantGas: 150000},
+		{name: "override for another method", limits: map[string]uint64{"withdraw": 90000}, wantGas: 21000, wantEstimates: 1},
+		{name: "no overrides", wantGas: 21000, wantEstimates: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t)
+			WithGasLimits(tt.limits)(client)
+
+			tx, err := client.Deposit(context.Background(), big.NewInt(1e18))
+			if err != nil {
+				t.Fatalf("Deposit failed: %v", err)
+			}
+			if tx.Gas() != tt.wantGas {
+				t.Errorf("expected gas limit %d, got %d", tt.wantGas, tx.Gas())
+			}
+			if calls := backend.MethodCalls("EstimateGas"); calls != tt.wantEstimates {
+				t.Errorf("expected %d EstimateGas calls, got %d", tt.wantEstimates, calls)
+			}
+		})
+	}
+}
+
+// fixedPrices is a PriceProvider backed by a static price table
+type fixedPrices map[common.Address]float64
+
+func (p fixedPrices) PriceUSD(ctx context.Context, token common.Address) (*big.Float, error) {
+	price, ok := p[token]
+	if !ok {
+		return nil, errors.New("no price")
+	}
+	return big.NewFloat(price), nil
+}
+
+func TestGetTVLUSDSingleAsset(t *testing.T) {
+	stakingToken := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	client, backend := newTestClient(t)
+	WithStakingToken(stakingToken)(client)
+	WithStakingTokenDecimals(18)(client)
+	WithPriceProvider(fixedPrices{stakingToken: 2.5})(client)
+	if err := backend.SetCallResultFor(erc20ABI.Methods["balanceOf"], []interface{}{testContractAddress}, tokens(1200, 18)); err != nil {
+		t.Fatal(err)
+	}
+
+	tvl, err := client.GetTVLUSD(context.Background())
+	if err != nil {
+		t.Fatalf("GetTVLUSD failed: %v", err)
+	}
+	assertFloat(t, "TVL", tvl, 3000)
+
+	for _, call := range backend.Calls() {
+		if *call.To != stakingToken {
+			t.Errorf("expected the TVL to be read from the staking token, got a call to %s", call.To.Hex())
+		}
+	}
+}
+
+func TestGetTVLUSDSumsPoolAssets(t *testing.T) {
+	usdc := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	weth := common.HexToAddress("0x00000000000000000000000000000000000000c2")
+
+	client, backend := newTestClient(t)
+	WithPoolAssets(PoolAsset{Token: usdc, Decimals: 6}, PoolAsset{Token: weth, Decimals: 18})(client)
+	WithPriceProvider(fixedPrices{usdc: 1, weth: 2000})(client)
+
+	balanceOf := erc20ABI.Methods["balanceOf"]
+	if err := backend.SetCallResultAt(usdc, balanceOf, big.NewInt(1500000000)); err != nil { // 1500 USDC
+		t.Fatal(err)
+	}
+	if err := backend.SetCallResultAt(weth, balanceOf, big.NewInt(5e17)); err != nil { // 0.5 WETH
+		t.Fatal(err)
+	}
+
+	tvl, err := client.GetTVLUSD(context.Background())
+	if err != nil {
+		t.Fatalf("GetTVLUSD failed: %v", err)
+	}
+	assertFloat(t, "TVL", tvl, 2500)
+}
+
+func TestGetTVLUSDRequiresPrices(t *testing.T) {
+	client, _ := newTestClient(t)
+	if _, err := client.GetTVLUSD(context.Background()); err == nil {
+		t.Error("expected an error without a price provider")
+	}
+
+	WithPriceProvider(fixedPrices{})(client)
+	if _, err := client.GetTVLUSD(context.Background()); !errors.Is(err, ErrTokenNotConfigured) {
+		t.Errorf("expected ErrTokenNotConfigured, got %v", err)
+	}
+}
+
+// setPairState makes lpToken report a USDC/WETH pair with the given reserves and supply
+func setPairState(t *testing.T, backend *ethtest.Backend, lpToken, usdc, weth common.Address, reserve0, reserve1, supply *big.Int) {
+	t.Helper()
+
+	results := []struct {
+		address common.Address
+		method  abi.Method
+		values  []interface{}
+	}{
+		{lpToken, uniswapV2PairABI.Methods["token0"], []interface{}{usdc}},
+		{lpToken, uniswapV2PairABI.Methods["token1"], []interface{}{weth}},
+		{lpToken, uniswapV2PairABI.Methods["getReserves"], []interface{}{reserve0, reserve1, uint32(1700000000)}},
+		{lpToken, uniswapV2PairABI.Methods["totalSupply"], []interface{}{supply}},
+		{usdc, erc20ABI.Methods["decimals"], []interface{}{uint8(6)}},
+		{weth, erc20ABI.Methods["decimals"], []interface{}{uint8(18)}},
+	}
+	for _, result := range results {
+		if err := backend.SetCallResultAt(result.address, result.method, result.values...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetLPValueUSD(t *testing.T) {
+	lpToken := common.HexToAddress("0x00000000000000000000000000000000000000d0")
+	usdc := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	weth := common.HexToAddress("0x00000000000000000000000000000000000000c2")
+
+	client, backend := newTestClient(t)
+	WithPriceProvider(fixedPrices{usdc: 1, weth: 2000})(client)
+	// 2,000,000 USDC and 1000 WETH back 1000 LP tokens
+	setPairState(t, backend, lpToken, usdc, weth, big.NewInt(2000000000000), tokens(1000, 18), tokens(1000, 18))
+
+	reserves, err := client.GetLPReserves(context.Background(), lpToken)
+	if err != nil {
+		t.Fatalf("GetLPReserves failed: %v", err)
+	}
+	if reserves.Token0 != usdc || reserves.Token1 != weth || reserves.TotalSupply.Cmp(tokens(1000, 18)) != 0 {
+		t.Errorf("unexpected reserves %+v", reserves)
+	}
+
+	// 10 LP tokens hold 1% of each reserve: 20,000 USDC and 10 WETH
+	value, err := client.GetLPValueUSD(context.Background(), lpToken, tokens(10, 18))
+	if err != nil {
+		t.Fatalf("GetLPValueUSD failed: %v", err)
+	}
+	assertFloat(t, "LP value", value, 40000)
+}
+
+func TestGetLPValueUSDEmptyPair(t *testing.T) {
+	lpToken := common.HexToAddress("0x00000000000000000000000000000000000000d0")
+	usdc := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	weth := common.HexToAddress("0x00000000000000000000000000000000000000c2")
+
+	client, backend := newTestClient(t)
+	if _, err := client.GetLPValueUSD(context.Background(), lpToken, tokens(10, 18)); err == nil {
+		t.Error("expected an error without a price provider")
+	}
+
+	WithPriceProvider(fixedPrices{usdc: 1, weth: 2000})(client)
+	setPairState(t, backend, lpToken, usdc, weth, big.NewInt(0), big.NewInt(0), big.NewInt(0))
+	value, err := client.GetLPValueUSD(context.Background(), lpToken, tokens(10, 18))
+	if err != nil {
+		t.Fatalf("GetLPValueUSD failed: %v", err)
+	}
+	if value.Sign() != 0 {
+		t.Errorf("expected an empty pair to be worth nothing, got %s", value.Text('f', 2))
+	}
+}
+
+func TestGetPositionNFTs(t *testing.T) {
+	manager := common.HexToAddress("0x00000000000000000000000000000000000000e0")
+	token0 := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	token1 := common.HexToAddress("0x00000000000000000000000000000000000000c2")
+
+	client, backend := newTestClient(t)
+	WithPositionManager(manager)(client)
+	owner := client.from()
+
+	if err := backend.SetCallResultAt(manager, positionManagerABI.Methods["balanceOf"], big.NewInt(2)); err != nil {
+		t.Fatal(err)
+	}
+	positions := map[int64]struct {
+		tokenID   int64
+		tickLower int64
+		liquidity *big.Int
+	}{
+		0: {tokenID: 7, tickLower: -887220, liquidity: tokens(5, 18)},
+		1: {tokenID: 9, tickLower: 100, liquidity: big.NewInt(0)},
+	}
+	for index, position := range positions {
+		if err := backend.SetCallResultFor(positionManagerABI.Methods["tokenOfOwnerByIndex"], []interface{}{owner, big.NewInt(index)}, big.NewInt(position.tokenID)); err != nil {
+			t.Fatal(err)
+		}
+		if err := backend.SetCallResultFor(positionManagerABI.Methods["positions"], []interface{}{big.NewInt(position.tokenID)},
+			big.NewInt(0), common.Address{}, token0, token1, big.NewInt(3000), big.NewInt(position.tickLower), big.NewInt(887220),
+			position.liquidity, big.NewInt(0), big.NewInt(0), big.NewInt(11), big.NewInt(12)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nfts, err := client.GetPositionNFTs(context.Background(), owner)
+	if err != nil {
+		t.Fatalf("GetPositionNFTs failed: %v", err)
+	}
+	if len(nfts) != 2 {
+		t.Fatalf("expected 2 position NFTs, got %d", len(nfts))
+	}
+	for i, nft := range nfts {
+		want := positions[int64(i)]
+		if nft.TokenID.Int64() != want.tokenID || nft.Liquidity.Cmp(want.liquidity) != 0 || nft.TickLower != int32(want.tickLower) {
+			t.Errorf("NFT %d: unexpected %+v", i, nft)
+		}
+		if nft.Token0 != token0 || nft.Token1 != token1 || nft.Fee != 3000 || nft.TickUpper != 887220 ||
+			nft.TokensOwed0.Int64() != 11 || nft.TokensOwed1.Int64() != 12 {
+			t.Errorf("NFT %d: unexpected pair details %+v", i, nft)
+		}
+	}
+	for _, call := range backend.Calls() {
+		if *call.To != manager {
+			t.Errorf("expected every read from the position manager, got a call to %s", call.To.Hex())
+		}
+	}
+}
+
+func TestGetPositionNFTsRejectsHugeBalance(t *testing.T) {
+	client, backend := newTestClient(t)
+	if err := backend.SetCallResult(positionManagerABI.Methods["balanceOf"], new(big.Int).Lsh(big.NewInt(1), 62)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.GetPositionNFTs(context.Background(), client.from()); err == nil {
+		t.Fatal("expected an error for a balance beyond maxPositionNFTs")
+	}
+	if calls := backend.MethodCalls("CallContract"); calls != 1 {
+		t.Errorf("expected only the balance to be read, got %d contract calls", calls)
+	}
+}
+
+const claimRewardsForNFTABI = `{"type":"function","name":"claimRewards","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[]}`
+
+func TestClaimRewardsForNFT(t *testing.T) {
+	client, _ := newTestClient(t)
+	if _, err := client.ClaimRewardsForNFT(context.Background(), big.NewInt(7)); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Fatalf("expected ErrUnsupportedMethod without a token ID overload, got %v", err)
+	}
+
+	client, backend := newTestClient(t, claimRewardsForNFTABI)
+	tx, err := client.ClaimRewardsForNFT(context.Background(), big.NewInt(7))
+	if err != nil {
+		t.Fatalf("ClaimRewardsForNFT failed: %v", err)
+	}
+	want, err := client.packOverload("claimRewards", big.NewInt(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tx.Data(), want) {
+		t.Errorf("expected claimRewards(7) data %x, got %x", want, tx.Data())
+	}
+	if sent := len(backend.SentTransactions()); sent != 1 {
+		t.Errorf("expected 1 sent transaction, got %d", sent)
+	}
+}
+
+const rewardAccountingABI = `[
+	{"type":"function","name":"poolInfo","stateMutability":"view","inputs":[{"name":"","type":"uint256"}],"outputs":[
+		{"name":"lpToken","type":"address"},
+		{"name":"allocPoint","type":"uint256"},
+		{"name":"lastRewardBlock","type":"uint256"},
+		{"name":"accSushiPerShare","type":"uint256"}
+	]},
+	{"type":"function","name":"totalAllocPoint","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+func TestGetPoolRewardAccounting(t *testing.T) {
+	client, backend := newMasterChefClient(t, rewardAccountingABI)
+	accPerShare, _ := new(big.Int).SetString("123456789012345678901234", 10)
+	setCallResult(t, client, backend, "poolInfo", common.HexToAddress("0x03"), big.NewInt(40), big.NewInt(18000000), accPerShare)
+	setCallResult(t, client, backend, "totalAllocPoint", big.NewInt(1000))
+
+	accounting, err := client.GetPoolRewardAccounting(context.Background(), big.NewInt(2))
+	if err != nil {
+		t.Fatalf("GetPoolRewardAccounting failed: %v", err)
+	}
+
+	if accounting.AccRewardPerShare.Cmp(accPerShare) != 0 {
+		t.Errorf("expected accRewardPerShare %s, got %s", accPerShare, accounting.AccRewardPerShare)
+	}
+	if accounting.AllocPoint.Int64() != 40 {
+		t.Errorf("expected allocPoint 40, got %s", accounting.AllocPoint)
+	}
+	if accounting.TotalAllocPoint.Int64() != 1000 {
+		t.Errorf("expected totalAllocPoint 1000, got %s", accounting.TotalAllocPoint)
+	}
+	if accounting.LastRewardBlock.Int64() != 18000000 {
+		t.Errorf("expected lastRewardBlock 18000000, got %s", accounting.LastRewardBlock)
+	}
+
+	calls := backend.Calls()
+	if len(calls) == 0 {
+		t.Fatal("expected poolInfo to be called")
+	}
+	args, err := client.contractABI.Methods["poolInfo"].Inputs.Unpack(calls[0].Data[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid := args[0].(*big.Int); pid.Int64() != 2 {
+		t.Errorf("expected pool id 2, got %s", pid)
+	}
+}
+
+func TestGetPoolRewardAccountingRequiresPoolInfo(t *testing.T) {
+	client, _ := newTestClient(t)
+	if _, err := client.GetPoolRewardAccounting(context.Background(), big.NewInt(0)); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("expected ErrUnsupportedMethod, got %v", err)
+	}
+}
+
+const poolWeightsABI = `[
+	{"type":"function","name":"poolInfo","stateMutability":"view","inputs":[{"name":"","type":"uint256"}],"outputs":[
+		{"name":"lpToken","type":"address"},
+		{"name":"allocPoint","type":"uint256"},
+		{"name":"lastRewardBlock","type":"uint256"},
+		{"name":"accSushiPerShare","type":"uint256"}
+	]},
+	{"type":"function","name":"totalAllocPoint","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"poolLength","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+func TestGetPoolWeights(t *testing.T) {
+	client, backend := newMasterChefClient(t, poolWeightsABI)
+	allocPoints := []int64{100, 300, 0, 600}
+	setCallResult(t, client, backend, "poolLength", big.NewInt(int64(len(allocPoints))))
+	setCallResult(t, client, backend, "totalAllocPoint", big.NewInt(1000))
+	for pid, alloc := range allocPoints {
+		if err := backend.SetCallResultFor(client.contractABI.Methods["poolInfo"], []interface{}{big.NewInt(int64(pid))},
+			common.HexToAddress("0x03"), big.NewInt(alloc), big.NewInt(18000000), big.NewInt(0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	weights, err := client.GetPoolWeights(context.Background())
+	if err != nil {
+		t.Fatalf("GetPoolWeights failed: %v", err)
+	}
+	if len(weights) != len(allocPoints) {
+		t.Fatalf("expected %d weights, got %d", len(allocPoints), len(weights))
+	}
+	for pid, alloc := range allocPoints {
+		assertFloat(t, fmt.Sprintf("pool %d weight", pid), weights[uint64(pid)], float64(alloc)/1000)
+	}
+}
+
+func TestGetPoolWeightsZeroTotalAllocPoint(t *testing.T) {
+	client, backend := newMasterChefClient(t, poolWeightsABI)
+	setCallResult(t, client, backend, "poolLength", big.NewInt(2))
+	setCallResult(t, client, backend, "totalAllocPoint", big.NewInt(0))
+	setCallResult(t, client, backend, "poolInfo", common.HexToAddress("0x03"), big.NewInt(0), big.NewInt(0), big.NewInt(0))
+
+	weights, err := client.GetPoolWeights(context.Background())
+	if err != nil {
+		t.Fatalf("GetPoolWeights failed: %v", err)
+	}
+	for pid, weight := range weights {
+		if weight.Sign() != 0 {
+			t.Errorf("expected zero weight for pool %d, got %s", pid, weight.Text('g', 10))
+		}
+	}
+}
+
+func TestGetPoolWeightsRequiresPoolLength(t *testing.T) {
+	client, _ := newMasterChefClient(t, rewardAccountingABI)
+	if _, err := client.GetPoolWeights(context.Background()); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("expected ErrUnsupportedMethod, got %v", err)
+	}
+}
+
+func TestListPoolsRejectsHugePoolLength(t *testing.T) {
+	client, backend := newMasterChefClient(t, poolWeightsABI)
+	setCallResult(t, client, backend, "poolLength", new(big.Int).Lsh(big.NewInt(1), 62))
+
+	if _, err := client.ListPools(context.Background()); err == nil {
+		t.Fatal("expected an error for a pool length beyond maxPools")
+	}
+	if _, err := client.GetPoolWeights(context.Background()); err == nil {
+		t.Fatal("expected GetPoolWeights to reject a pool length beyond maxPools")
+	}
+	if calls := backend.MethodCalls("CallContract"); calls != 2 {
+		t.Errorf("expected only the pool length to be read, got %d contract calls", calls)
+	}
+}
+
+const accRewardPerShareABI = `{"type":"function","name":"accRewardPerShare","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]}`
+
+func TestReconcileRewards(t *testing.T) {
+	tests := []struct {
+		name        string
+		reported    *big.Int
+		discrepancy bool
+	}{
+		// 10 tokens staked at 3e11 per share is 3 tokens, less 1 token of reward debt
+		{"match", tokens(2, 18), false},
+		{"within tolerance", new(big.Int).Add(tokens(2, 18), tokens(1, 16)), false},
+		{"diverged", tokens(3, 18), true},
+		{"underpaid", tokens(1, 18), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t, userInfoABI, pendingRewardsABI, accRewardPerShareABI)
+			user := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+			setCallResult(t, client, backend, "userInfo", tokens(10, 18), tokens(1, 18))
+			setCallResult(t, client, backend, "pendingRewards", tt.reported)
+			setCallResult(t, client, backend, "accRewardPerShare", big.NewInt(3e11))
+
+			report, err := client.ReconcileRewards(context.Background(), user)
+			if err != nil {
+				t.Fatalf("ReconcileRewards failed: %v", err)
+			}
+			if report.Expected.Cmp(tokens(2, 18)) != 0 {
+				t.Errorf("expected 2 tokens pending, got %s", report.Expected)
+			}
+			if report.Reported.Cmp(tt.reported) != 0 || report.Difference.Cmp(new(big.Int).Sub(tt.reported, report.Expected)) != 0 {
+				t.Errorf("unexpected reported %s and difference %s", report.Reported, report.Difference)
+			}
+			if report.Discrepancy != tt.discrepancy {
+				t.Errorf("expected discrepancy %v within %d bps, got %v", tt.discrepancy, report.ToleranceBps, report.Discrepancy)
+			}
+		})
+	}
+}
+
+func TestReconcileRewardsRequiresAccumulator(t *testing.T) {
+	client, _ := newTestClient(t, userInfoABI, pendingRewardsABI)
+	if _, err := client.ReconcileRewards(context.Background(), common.Address{}); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("expected ErrUnsupportedMethod without an accumulator view, got %v", err)
+	}
+}
+
+// waitForInFlight blocks until client has n in-flight WaitForTransaction calls
+func waitForInFlight(t *testing.T, client *YieldFarmingClient, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		client.lifecycleMu.Lock()
+		waiting := len(client.waiting)
+		client.lifecycleMu.Unlock()
+		if waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d in-flight waits", n)
+}
+
+func TestShutdownWaitsForInFlightTransactions(t *testing.T) {
+	client, backend := newTestClient(t)
+	ctx := context.Background()
+
+	tx, err := client.ClaimRewards(ctx)
+	if err != nil {
+		t.Fatalf("ClaimRewards failed: %v", err)
+	}
+	go client.WaitForTransaction(ctx, tx)
+	waitForInFlight(t, client, 1)
+
+	shutdownDone := make(chan error, 1)
+	go func() {
+		_, err := client.Shutdown(ctx)
+		shutdownDone <- err
+	}()
+
+	select {
+	case err := <-shutdownDone:
+		t.Fatalf("Shutdown returned before the transaction was mined: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if _, err := client.ClaimRewards(ctx); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("expected sends during shutdown to fail with ErrClientClosed, got %v", err)
+	}
+
+	backend.SetReceipt(tx.Hash(), &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: tx.Hash(), BlockNumber: big.NewInt(2)})
+	select {
+	case err := <-shutdownDone:
+		if err != nil {
+			t.Fatalf("Shutdown failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return after the transaction was mined")
+	}
+}
+
+func TestShutdownReturnsUnconfirmedOnTimeout(t *testing.T) {
+	client, _ := newTestClient(t)
+	waitCtx, cancelWait := context.WithCancel(context.Background())
+	defer cancelWait()
+
+	tx, err := client.ClaimRewards(waitCtx)
+	if err != nil {
+		t.Fatalf("ClaimRewards failed: %v", err)
+	}
+	go client.WaitForTransaction(waitCtx, tx)
+	waitForInFlight(t, client, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	unconfirmed, err := client.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if len(unconfirmed) != 1 || unconfirmed[0] != tx.Hash() {
+		t.Errorf("expected %s to be unconfirmed, got %v", tx.Hash().Hex(), unconfirmed)
+	}
+}
+
+const poolEventsABI = `
+	{"type":"event","name":"Deposit","inputs":[{"name":"user","type":"address","indexed":true},{"name":"amount","type":"uint256","indexed":false}]},
+	{"type":"event","name":"Withdraw","inputs":[{"name":"user","type":"address","indexed":true},{"name":"amount","type":"uint256","indexed":false}]},
+	{"type":"event","name":"RewardPaid","inputs":[{"name":"user","type":"address","indexed":true},{"name":"reward","type":"uint256","indexed":false}]}`
+
+// addPoolEvent appends a synthetic pool event log for user to backend
+func addPoolEvent(t *testing.T, client *YieldFarmingClient, backend *ethtest.Backend, name string, user common.Address, amount int64, block uint64) {
+	t.Helper()
+
+	event := client.contractABI.Events[name]
+	data, err := event.Inputs.NonIndexed().Pack(big.NewInt(amount))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.AddLog(types.Log{
+		Address:     testContractAddress,
+		Topics:      []common.Hash{event.ID, common.BytesToHash(user.Bytes())},
+		Data:        data,
+		BlockNumber: block,
+	})
+}
+
+func TestComputeNetFlows(t *testing.T) {
+	client, backend := newTestClient(t, poolEventsABI)
+	WithLogPageSize(100)(client)
+	user := common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	other := common.HexToAddress("0x00000000000000000000000000000000000000f2")
+
+	addPoolEvent(t, client, backend, "Deposit", user, 1000, 10)
+	addPoolEvent(t, client, backend, "Deposit", user, 500, 150)
+	addPoolEvent(t, client, backend, "Withdraw", user, 300, 220)
+	addPoolEvent(t, client, backend, "RewardPaid", user, 40, 120)
+	addPoolEvent(t, client, backend, "RewardPaid", user, 60, 250)
+	// Ignored: another user's deposit and a deposit outside the range
+	addPoolEvent(t, client, backend, "Deposit", other, 9999, 50)
+	addPoolEvent(t, client, backend, "Deposit", user, 7777, 251)
+
+	flows, err := client.ComputeNetFlows(context.Background(), user, 1, 250)
+	if err != nil {
+		t.Fatalf("ComputeNetFlows failed: %v", err)
+	}
+
+	for _, check := range []struct {
+		name string
+		got  *big.Int
+		want int64
+	}{
+		{"deposited", flows.Deposited, 1500},
+		{"withdrawn", flows.Withdrawn, 300},
+		{"claimed", flows.Claimed, 100},
+		{"net", flows.Net, -1100},
+	} {
+		if check.got.Int64() != check.want {
+			t.Errorf("%s = %s, want %d", check.name, check.got, check.want)
+		}
+	}
+
+	// Three events over blocks 1-250 in 100-block pages
+	queries := backend.LogQueries()
+	if len(queries) != 9 {
+		t.Fatalf("expected 9 paged queries, got %d", len(queries))
+	}
+	if from, to := queries[2].FromBlock.Uint64(), queries[2].ToBlock.Uint64(); from != 201 || to != 250 {
+		t.Errorf("expected the last page to span 201-250, got %d-%d", from, to)
+	}
+}
+
+const userInfoABI = `{"type":"function","name":"userInfo","stateMutability":"view","inputs":[{"name":"user","type":"address"}],"outputs":[{"name":"amount","type":"uint256"},{"name":"rewardDebt","type":"uint256"}]}`
+
+// newAutoClaimTestClient returns a client reading its position on-chain, whose auto-claim
+// ticks are driven by the returned fake ticker while receipt polling runs on real time
+func newAutoClaimTestClient(t *testing.T, pending *big.Int) (*YieldFarmingClient, *ethtest.Backend, *fakeTicker) {
+	t.Helper()
+
+	client, backend := newTestClient(t, userInfoABI, pendingRewardsABI)
+	WithReceiptPollInterval(time.Millisecond)(client)
+	if err := backend.SetCallResult(client.contractABI.Methods["userInfo"], big.NewInt(1e18), big.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.SetCallResult(client.contractABI.Methods["pendingRewards"], pending); err != nil {
+		t.Fatal(err)
+	}
+
+	ft := newFakeTicker()
+	client.newTicker = func(d time.Duration) ticker {
+		if d == time.Hour {
+			return ft
+		}
+		return timeTicker{time.NewTicker(d)}
+	}
+	return client, backend, ft
+}
+
+func TestStartAutoClaimClaimsOnceWhilePending(t *testing.T) {
+	client, backend, ft := newAutoClaimTestClient(t, big.NewInt(5e17))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := client.StartAutoClaim(ctx, time.Hour, big.NewInt(1e17))
+
+	ft.ch <- time.Now()
+	waitForMethodCalls(t, backend, "SendTransaction", 1)
+	reads := backend.MethodCalls("CallContract")
+
+	// The claim is unconfirmed, so these ticks must neither read nor send again. The
+	// second send only completes once the first tick has been handled.
+	ft.ch <- time.Now()
+	ft.ch <- time.Now()
+	if sent := len(backend.SentTransactions()); sent != 1 {
+		t.Fatalf("expected a single claim while the first is pending, got %d", sent)
+	}
+	if calls := backend.MethodCalls("CallContract"); calls != reads {
+		t.Errorf("expected skipped ticks not to read the position, got %d reads after %d", calls, reads)
+	}
+
+	tx := backend.SentTransactions()[0]
+	backend.SetReceipt(tx.Hash(), &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: tx.Hash(), GasUsed: 52000, BlockNumber: big.NewInt(2)})
+
+	select {
+	case result := <-results:
+		if result.Err != nil {
+			t.Fatalf("unexpected claim error: %v", result.Err)
+		}
+		if result.TxHash != tx.Hash() {
+			t.Errorf("expected tx %s, got %s", tx.Hash().Hex(), result.TxHash.Hex())
+		}
+		if result.Amount.Cmp(big.NewInt(5e17)) != 0 {
+			t.Errorf("expected amount 5e17, got %s", result.Amount)
+		}
+		if result.GasUsed != 52000 {
+			t.Errorf("expected 52000 gas used, got %d", result.GasUsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no claim result after the receipt appeared")
+	}
+
+	cancel()
+	if _, ok := <-results; ok {
+		t.Fatal("expected the result channel to be closed after cancellation")
+	}
+	<-ft.stopped
+}
+
+func TestStartAutoClaimSkipsBelowThreshold(t *testing.T) {
+	client, backend, ft := newAutoClaimTestClient(t, big.NewInt(1e16))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := client.StartAutoClaim(ctx, time.Hour, big.NewInt(1e17))
+
+	ft.ch <- time.Now()
+	ft.ch <- time.Now()
+	waitForMethodCalls(t, backend, "CallContract", 2)
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Fatalf("expected no claim below the threshold, got %d transactions", sent)
+	}
+
+	cancel()
+	if result, ok := <-results; ok {
+		t.Fatalf("expected no results below the threshold, got %+v", result)
+	}
+}
+
+func TestStartAutoClaimRejectsNonPositiveInterval(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	results := client.StartAutoClaim(context.Background(), 0, big.NewInt(1))
+	if result, ok := <-results; !ok || result.Err == nil {
+		t.Fatal("expected an error result")
+	}
+	if _, ok := <-results; ok {
+		t.Fatal("expected the channel to be closed")
+	}
+}
+
+const stakerCountABI = `{"type":"function","name":"totalStakers","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]}`
+
+func TestGetStakerCountReadsView(t *testing.T) {
+	client, backend := newTestClient(t, stakerCountABI, poolEventsABI)
+	WithStakerCountFromEvents(1)(client)
+	setCallResult(t, client, backend, "totalStakers", big.NewInt(42))
+
+	count, err := client.GetStakerCount(context.Background())
+	if err != nil {
+		t.Fatalf("GetStakerCount failed: %v", err)
+	}
+	if count.Int64() != 42 {
+		t.Errorf("expected 42 stakers, got %s", count)
+	}
+	if queries := backend.LogQueries(); len(queries) != 0 {
+		t.Errorf("expected no log queries when the view exists, got %d", len(queries))
+	}
+}
+
+func TestGetStakerCountFromEvents(t *testing.T) {
+	client, backend := newTestClient(t, poolEventsABI)
+	backend.Head.Number = big.NewInt(300)
+	first := common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	second := common.HexToAddress("0x00000000000000000000000000000000000000f2")
+	third := common.HexToAddress("0x00000000000000000000000000000000000000f3")
+
+	addPoolEvent(t, client, backend, "Deposit", first, 1000, 10)
+	addPoolEvent(t, client, backend, "Deposit", first, 500, 150)
+	addPoolEvent(t, client, backend, "Deposit", second, 700, 200)
+	addPoolEvent(t, client, backend, "Withdraw", third, 300, 220)
+	// Ignored: a deposit before the start block
+	addPoolEvent(t, client, backend, "Deposit", third, 100, 4)
+
+	if _, err := client.GetStakerCount(context.Background()); !errors.Is(err, ErrNotSupported) || !errors.Is(err, ErrUnsupportedMethod) {
+		t.Fatalf("expected ErrNotSupported without the events flag, got %v", err)
+	}
+	if queries := backend.LogQueries(); len(queries) != 0 {
+		t.Errorf("expected no log queries without the events flag, got %d", len(queries))
+	}
+
+	WithStakerCountFromEvents(5)(client)
+	count, err := client.GetStakerCount(context.Background())
+	if err != nil {
+		t.Fatalf("GetStakerCount failed: %v", err)
+	}
+	if count.Int64() != 2 {
+		t.Errorf("expected 2 unique depositors, got %s", count)
+	}
+}
+
+func TestComputeNetFlowsRejectsInvertedRange(t *testing.T) {
+	client, _ := newTestClient(t, poolEventsABI)
+	if _, err := client.ComputeNetFlows(context.Background(), common.Address{}, 10, 9); err == nil {
+		t.Error("expected an error for an inverted block range")
+	}
+}
+
+func TestWaitForTransactionPollsAtConfiguredInterval(t *testing.T) {
+	client, backend := newTestClient(t)
+	WithReceiptPollInterval(250 * time.Millisecond)(client)
+
+	ft := newFakeTicker()
+	var interval time.Duration
+	client.newTicker = func(d time.Duration) ticker {
+		interval = d
+		return ft
+	}
+	ctx := context.Background()
+
+	tx, err := client.ClaimRewards(ctx)
+	if err != nil {
+		t.Fatalf("ClaimRewards failed: %v", err)
+	}
+
+	type result struct {
+		receipt *types.Receipt
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		receipt, err := client.WaitForTransaction(ctx, tx)
+		done <- result{receipt, err}
+	}()
+
+	// The first poll happens immediately; each tick triggers another
+	for i := 0; i < 2; i++ {
+		ft.ch <- time.Now()
+	}
+	waitForMethodCalls(t, backend, "TransactionReceipt", 3)
+	backend.SetReceipt(tx.Hash(), &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: tx.Hash(), BlockNumber: big.NewInt(2)})
+	ft.ch <- time.Now()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			t.Fatalf("WaitForTransaction failed: %v", res.err)
+		}
+		if res.receipt.TxHash != tx.Hash() {
+			t.Errorf("unexpected receipt for %s", res.receipt.TxHash.Hex())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WaitForTransaction did not return after the receipt appeared")
+	}
+
+	if interval != 250*time.Millisecond {
+		t.Errorf("expected a 250ms poll interval, got %s", interval)
+	}
+	if polls := backend.MethodCalls("TransactionReceipt"); polls != 4 {
+		t.Errorf("expected 4 receipt polls, got %d", polls)
+	}
+	select {
+	case <-ft.stopped:
+	default:
+		t.Error("expected the poll ticker to be stopped")
+	}
+}
+
+func TestWaitForTransactionReturnsReceiptErrors(t *testing.T) {
+	client, backend := newTestClient(t)
+	client.newTicker = func(time.Duration) ticker { return newFakeTicker() }
+
+	tx, err := client.ClaimRewards(context.Background())
+	if err != nil {
+		t.Fatalf("ClaimRewards failed: %v", err)
+	}
+
+	backend.Err = errors.New("rate limited")
+	if _, err := client.WaitForTransaction(context.Background(), tx); err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Fatalf("expected the receipt error, got %v", err)
+	}
+}
+
+func TestWaitForTransactionCancelledReturnsHash(t *testing.T) {
+	client, backend := newTestClient(t)
+	client.newTicker = func(time.Duration) ticker { return newFakeTicker() }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tx, err := client.ClaimRewards(ctx)
+	if err != nil {
+		t.Fatalf("ClaimRewards failed: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.WaitForTransaction(ctx, tx)
+		done <- err
+	}()
+	waitForMethodCalls(t, backend, "TransactionReceipt", 1)
+	cancel()
+
+	select {
+	case err := <-done:
+		var cancelled *WaitCancelledError
+		if !errors.As(err, &cancelled) {
+			t.Fatalf("expected a WaitCancelledError, got %v", err)
+		}
+		if cancelled.TxHash != tx.Hash() {
+			t.Errorf("expected hash %s, got %s", tx.Hash().Hex(), cancelled.TxHash.Hex())
+		}
+		if !errors.Is(err, ErrWaitCancelled) || !errors.Is(err, context.Canceled) {
+			t.Errorf("expected ErrWaitCancelled wrapping context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WaitForTransaction did not return after cancellation")
+	}
+}
+
+func TestWaitForTransactionWithProgressReportsConfirmations(t *testing.T) {
+	client, backend := newTestClient(t)
+	WithConfirmations(4)(client)
+	tickers := make(chan *fakeTicker, 2)
+	client.newTicker = func(time.Duration) ticker {
+		ft := newFakeTicker()
+		tickers <- ft
+		return ft
+	}
+	ctx := context.Background()
+
+	tx, err := client.ClaimRewards(ctx)
+	if err != nil {
+		t.Fatalf("ClaimRewards failed: %v", err)
+	}
+	backend.SetBlockNumber(10)
+	backend.SetReceipt(tx.Hash(), &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: tx.Hash(), BlockNumber: big.NewInt(10)})
+
+	progress := make(chan [2]uint64, 4)
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.WaitForTransactionWithProgress(ctx, tx, func(current, target uint64) {
+			progress <- [2]uint64{current, target}
+		})
+		done <- err
+	}()
+
+	expectProgress := func(current uint64) {
+		t.Helper()
+		select {
+		case got := <-progress:
+			if got != [2]uint64{current, 4} {
+				t.Errorf("expected %d/4 confirmations, got %d/%d", current, got[0], got[1])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no progress reported for confirmation %d", current)
+		}
+	}
+	expectProgress(1)
+
+	<-tickers // the receipt poll's ticker
+	confirmTicker := <-tickers
+	backend.SetBlockNumber(11)
+	confirmTicker.ch <- time.Now()
+	expectProgress(2)
+
+	// A jump of two blocks still reports each confirmation
+	backend.SetBlockNumber(13)
+	confirmTicker.ch <- time.Now()
+	expectProgress(3)
+	expectProgress(4)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("WaitForTransactionWithProgress failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WaitForTransactionWithProgress did not return at the target depth")
+	}
+	select {
+	case got := <-progress:
+		t.Errorf("unexpected progress past the target: %v", got)
+	default:
+	}
+}
+
+// testMnemonic is the standard Hardhat/Anvil development mnemonic
+const testMnemonic = "test test test test test test test test test test test junk"
+
+func TestNewYieldFarmingClientFromMnemonic(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "m/44'/60'/0'/0/0", want: "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"},
+		{path: "m/44'/60'/0'/0/1", want: "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			// Dialling an HTTP endpoint doesn't connect until the first request
+			client, err := NewYieldFarmingClientFromMnemonic("http://127.0.0.1:0", testContractAddress, testMnemonic, tt.path)
+			if err != nil {
+				t.Fatalf("NewYieldFarmingClientFromMnemonic failed: %v", err)
+			}
+			if got := client.auth.From.Hex(); got != tt.want {
+				t.Errorf("expected address %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDeriveKeyFromMnemonicValidatesInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		mnemonic string
+		path     string
+	}{
+		{name: "bad checksum", mnemonic: strings.Repeat("test ", 12), path: "m/44'/60'/0'/0/0"},
+		{name: "unknown word", mnemonic: strings.Replace(testMnemonic, "junk", "junkk", 1), path: "m/44'/60'/0'/0/0"},
+		{name: "relative path", mnemonic: testMnemonic, path: "0/0"},
+		{name: "malformed path", mnemonic: testMnemonic, path: "m/44'/sixty'/0'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := deriveKeyFromMnemonic(tt.mnemonic, tt.path); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestSetSignerSwitchesAccount(t *testing.T) {
+	client, backend := newTestClient(t)
+	ctx := context.Background()
+	signer := types.NewEIP155Signer(big.NewInt(1))
+
+	first, err := client.ClaimRewards(ctx)
+	if err != nil {
+		t.Fatalf("ClaimRewards failed: %v", err)
+	}
+	firstSender, err := types.Sender(signer, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := crypto.PubkeyToAddress(key.PublicKey)
+	backend.SetNonce(want, 3)
+	if err := client.SetPrivateKey(common.Bytes2Hex(crypto.FromECDSA(key))); err != nil {
+		t.Fatalf("SetPrivateKey failed: %v", err)
+	}
+	if client.from() != want {
+		t.Errorf("expected From %s, got %s", want.Hex(), client.from().Hex())
+	}
+
+	second, err := client.ClaimRewards(ctx)
+	if err != nil {
+		t.Fatalf("ClaimRewards after switching failed: %v", err)
+	}
+	secondSender, err := types.Sender(signer, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secondSender != want || secondSender == firstSender {
+		t.Errorf("expected the second transaction from %s, got %s (first from %s)", want.Hex(), secondSender.Hex(), firstSender.Hex())
+	}
+	if second.Nonce() != 3 {
+		t.Errorf("expected the new account's nonce 3, got %d", second.Nonce())
+	}
+
+	client.SetSigner(nil)
+	if _, err := client.ClaimRewards(ctx); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly after clearing the signer, got %v", err)
+	}
+}
+
+func TestSetPrivateKeyRejectsInvalidKey(t *testing.T) {
+	client, _ := newTestClient(t)
+	before := client.from()
+
+	if err := client.SetPrivateKey("not-a-key"); err == nil {
+		t.Fatal("expected an error for an invalid key")
+	}
+	if client.from() != before {
+		t.Error("expected the account to be unchanged after a failed swap")
+	}
+}
+
+func TestCompoundingBenefitBreakEven(t *testing.T) {
+	// 10,000 tokens at 10% APR for a year, paying 1 token of gas per compound
+	const day = 24 * time.Hour
+	year := 365 * day
+
+	tests := []struct {
+		frequency time.Duration
+		want      float64
+	}{
+		{frequency: day, want: -313.4422},
+		{frequency: 7 * day, want: -1.3467},
+		{frequency: 14 * day, want: 23.6007},
+		{frequency: year, want: -1},
+	}
+
+	for _, tt := range tests {
+		got := compoundingBenefit(10000, 0.10, 1, tt.frequency, year)
+		if diff := got - tt.want; diff > 0.01 || diff < -0.01 {
+			t.Errorf("compounding every %s: benefit %.4f, want %.4f", tt.frequency, got, tt.want)
+		}
+	}
+
+	// Weekly compounding loses to gas; fortnightly is the first to come out ahead
+	if weekly := compoundingBenefit(10000, 0.10, 1, 7*day, year); weekly >= 0 {
+		t.Errorf("expected weekly compounding to lose money, got %.4f", weekly)
+	}
+	if fortnightly := compoundingBenefit(10000, 0.10, 1, 14*day, year); fortnightly <= 0 {
+		t.Errorf("expected fortnightly compounding to pay off, got %.4f", fortnightly)
+	}
+}
+
+func TestCompoundingBenefitUsesPositionAndGasPrice(t *testing.T) {
+	stakingToken := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	client, backend := newTestClient(t)
+	WithStakingToken(stakingToken)(client)
+	WithStakingTokenDecimals(18)(client)
+	WithRewardTokenDecimals(18)(client)
+	WithGasLimits(map[string]uint64{"compound": 100000})(client)
+	WithPriceProvider(fixedPrices{NativeToken: 2000, stakingToken: 1})(client)
+	backend.GasPrice = big.NewInt(5e9)
+	setPoolTVL(t, client, backend, tokens(1000, 18))
+
+	benefit, err := client.CompoundingBenefit(context.Background(), client.from(), 30*24*time.Hour, 365*24*time.Hour)
+	if err != nil {
+		t.Fatalf("CompoundingBenefit failed: %v", err)
+	}
+
+	// 100000 gas at 5 gwei is 0.0005 ETH, worth 1 staking token at these prices; the mock
+	// position stakes 10 tokens
+	apy, err := client.CalculateAPY(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rate, _ := apy.Float64()
+	want := compoundingBenefit(10, rate/100, 1, 30*24*time.Hour, 365*24*time.Hour)
+	assertFloat(t, "benefit", benefit, want)
+}
+
+func TestOptimalClaimFrequencyBalancesGasAgainstCompounding(t *testing.T) {
+	// 10,000 tokens at 10% APR paying 1 token of gas per compound; the square-root rule
+	// puts the optimum near sqrt(2 * 1 / (10000 * 0.1^2)) of a year, about 52 days
+	const day = 24 * time.Hour
+	year := 365 * day
+
+	interval, gain := optimalClaimFrequency(10000, 0.10, 1)
+	if interval < 45*day || interval > 60*day {
+		t.Errorf("expected an interval near 52 days, got %s", interval)
+	}
+	if want := compoundingBenefit(10000, 0.10, 1, interval, year); gain != want {
+		t.Errorf("expected the interval's benefit %.4f, got %.4f", want, gain)
+	}
+	claims := time.Duration(year / interval)
+	for _, neighbour := range []time.Duration{year / (claims - 1), year / (claims + 1), 14 * day, day} {
+		if other := compoundingBenefit(10000, 0.10, 1, neighbour, year); other > gain {
+			t.Errorf("compounding every %s earns %.4f, more than the optimum's %.4f", neighbour, other, gain)
+		}
+	}
+
+	// Gas dwarfing the yield leaves yearly compounding as the least bad choice
+	if interval, gain := optimalClaimFrequency(10, 0.10, 5); interval != year || gain >= 0 {
+		t.Errorf("expected a losing yearly interval, got %s gaining %.4f", interval, gain)
+	}
+}
+
+func TestOptimalClaimFrequencyUsesPositionAndGasPrice(t *testing.T) {
+	stakingToken := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	client, backend := newTestClient(t, userInfoABI, pendingRewardsABI, emissionScheduleABI)
+	WithStakingToken(stakingToken)(client)
+	WithStakingTokenDecimals(18)(client)
+	WithRewardTokenDecimals(18)(client)
+	WithGasLimits(map[string]uint64{"compound": 100000})(client)
+	WithPriceProvider(fixedPrices{NativeToken: 2000, stakingToken: 1})(client)
+	backend.GasPrice = big.NewInt(5e9)
+	setCallResult(t, client, backend, "userInfo", tokens(10000, 18), big.NewInt(0))
+	setCallResult(t, client, backend, "pendingRewards", big.NewInt(0))
+	setCallResult(t, client, backend, "periodFinish", big.NewInt(0))
+	// 100 tokens a year across the pool's 1000 staked tokens is a 10% APR
+	setPoolTVL(t, client, backend, tokens(1000, 18))
+	setCallResult(t, client, backend, "rewardRate", big.NewInt(3170979198376))
+
+	interval, gain, err := client.OptimalClaimFrequency(context.Background(), client.from())
+	if err != nil {
+		t.Fatalf("OptimalClaimFrequency failed: %v", err)
+	}
+
+	// 100000 gas at 5 gwei is 0.0005 ETH, worth 1 staking token at these prices
+	wantInterval, wantGain := optimalClaimFrequency(10000, 0.10, 1)
+	if interval != wantInterval {
+		t.Errorf("expected an interval of %s, got %s", wantInterval, interval)
+	}
+	assertFloat(t, "net gain", gain, wantGain)
+}
+
+func TestNetAPY(t *testing.T) {
+	stakingToken := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	rewardToken := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	const day = 24 * time.Hour
+
+	// The pool pays 1 reward token per second across 2000 staked tokens. A claim costs
+	// 100000 gas at 5 gwei, 0.0005 ETH or $1 at $2000 per ETH.
+	tests := []struct {
+		name      string
+		staked    *big.Int
+		frequency time.Duration
+		want      float64
+	}{
+		{
+			// 15.768 reward tokens a year worth $0.0015768 on a $0.001 stake, against $365 of gas
+			name:      "tiny position claimed daily",
+			staked:    big.NewInt(1e15),
+			frequency: day,
+			want:      (0.0015768 - 365) / 0.001 * 100,
+		},
+		{
+			// 157680 reward tokens a year worth $15.768 on a $10 stake, against $1 of gas
+			name:      "large position claimed yearly",
+			staked:    tokens(10, 18),
+			frequency: 365 * day,
+			want:      (15.768 - 1) / 10 * 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t, userInfoABI, pendingRewardsABI, emissionScheduleABI)
+			WithStakingToken(stakingToken)(client)
+			WithRewardToken(rewardToken)(client)
+			WithStakingTokenDecimals(18)(client)
+			WithRewardTokenDecimals(18)(client)
+			WithGasLimits(map[string]uint64{"claimRewards": 100000})(client)
+			WithPriceProvider(fixedPrices{NativeToken: 2000, stakingToken: 1, rewardToken: 0.0001})(client)
+			backend.GasPrice = big.NewInt(5e9)
+			setCallResult(t, client, backend, "userInfo", tt.staked, big.NewInt(0))
+			setCallResult(t, client, backend, "pendingRewards", big.NewInt(0))
+			setCallResult(t, client, backend, "rewardRate", tokens(1, 18))
+			setCallResult(t, client, backend, "periodFinish", big.NewInt(0))
+			if err := backend.SetCallResultAt(stakingToken, erc20ABI.Methods["balanceOf"], tokens(2000, 18)); err != nil {
+				t.Fatal(err)
+			}
+
+			apy, err := client.NetAPY(context.Background(), client.from(), tt.frequency)
+			if err != nil {
+				t.Fatalf("NetAPY failed: %v", err)
+			}
+			assertFloat(t, "net APY", apy, tt.want)
+		})
+	}
+}
+
+func TestNetAPYValidatesInput(t *testing.T) {
+	client, _ := newTestClient(t)
+	if _, err := client.NetAPY(context.Background(), client.from(), 0); err == nil {
+		t.Error("expected an error for a zero claim frequency")
+	}
+	if _, err := client.NetAPY(context.Background(), client.from(), time.Hour); err == nil {
+		t.Error("expected an error without a price provider")
+	}
+}
+
+func TestCompoundingBenefitValidatesInput(t *testing.T) {
+	client, _ := newTestClient(t)
+	if _, err := client.CompoundingBenefit(context.Background(), client.from(), 0, time.Hour); err == nil {
+		t.Error("expected an error for a zero frequency")
+	}
+	if _, err := client.CompoundingBenefit(context.Background(), client.from(), time.Hour, time.Hour); err == nil {
+		t.Error("expected an error without a price provider")
+	}
+}
+
+func TestPermitDigestMatchesEIP712(t *testing.T) {
+	domain := permitDomain{
+		Name:    "Staking Token",
+		Version: "1",
+		ChainID: big.NewInt(1),
+		Token:   common.HexToAddress("0x00000000000000000000000000000000000000aa"),
+	}
+	owner := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	value, nonce, deadline := tokens(5, 18), big.NewInt(3), big.NewInt(1700003600)
+
+	typedData := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"Permit": {
+				{Name: "owner", Type: "address"},
+				{Name: "spender", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "deadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "Permit",
+		Domain: apitypes.TypedDataDomain{
+			Name:              domain.Name,
+			Version:           domain.Version,
+			ChainId:           (*math.HexOrDecimal256)(domain.ChainID),
+			VerifyingContract: domain.Token.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"owner":    owner.Hex(),
+			"spender":  testContractAddress.Hex(),
+			"value":    value.String(),
+			"nonce":    nonce.String(),
+			"deadline": deadline.String(),
+		},
+	}
+	want, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := permitDigest(domain, owner, testContractAddress, value, nonce, deadline); !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("digest mismatch:\n got %x\nwant %x", got, want)
+	}
+}
+
+func TestSignPermitRecoversOwner(t *testing.T) {
+	token := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	client, backend := newTestClient(t)
+	if err := backend.SetCallResultAt(token, permitABI.Methods["name"], "Staking Token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.SetCallResultAt(token, permitABI.Methods["nonces"], big.NewInt(4)); err != nil {
+		t.Fatal(err)
+	}
+	backend.SetCallError(permitABI.Methods["version"], errors.New("execution reverted"))
+
+	amount, deadline := tokens(5, 18), big.NewInt(1700003600)
+	v, r, s, err := client.SignPermit(context.Background(), token, amount, deadline)
+	if err != nil {
+		t.Fatalf("SignPermit failed: %v", err)
+	}
+	if v != 27 && v != 28 {
+		t.Fatalf("expected v of 27 or 28, got %d", v)
+	}
+
+	// The token's version() reverts, so the domain falls back to version "1"
+	digest := permitDigest(permitDomain{Name: "Staking Token", Version: "1", ChainID: big.NewInt(1), Token: token},
+		client.from(), testContractAddress, amount, big.NewInt(4), deadline)
+	sig := append(append(r[:], s[:]...), v-27)
+	pub, err := crypto.SigToPub(digest.Bytes(), sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != client.from() {
+		t.Errorf("expected the permit to be signed by %s, got %s", client.from().Hex(), signer.Hex())
+	}
+}
+
+func TestSignPermitVersionErrors(t *testing.T) {
+	token := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	client, backend := newTestClient(t)
+	if err := backend.SetCallResultAt(token, permitABI.Methods["name"], "Staking Token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.SetCallResultAt(token, permitABI.Methods["nonces"], big.NewInt(4)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A version() that fails for any reason but a revert must not sign under a guessed domain
+	backend.SetCallError(permitABI.Methods["version"], errors.New("connection refused"))
+	if _, _, _, err := client.SignPermit(context.Background(), token, tokens(5, 18), big.NewInt(1700003600)); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("expected the version error to propagate, got %v", err)
+	}
+
+	// A nil call error makes version() return no data, as a view the token lacks does
+	backend.SetCallError(permitABI.Methods["version"], nil)
+	if _, _, _, err := client.SignPermit(context.Background(), token, tokens(5, 18), big.NewInt(1700003600)); err != nil {
+		t.Fatalf("expected a fallback to version 1, got %v", err)
+	}
+}
+
+func TestSignMessageMatchesPersonalSign(t *testing.T) {
+	// The first Hardhat/Anvil development account signing "hello" with personal_sign
+	const key = "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+	signer := common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+	want := common.FromHex("0xf16ea9a3478698f695fd1401bfe27e9e4a7e8e3da94aa72b021125e31fa899cc573c48ea3fe1d4ab61a9db10c19032026e3ed2dbccba5a178235ac27f94504311c")
+
+	client, err := NewYieldFarmingClientWithBackend(ethtest.NewBackend(), testContractAddress, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := client.SignMessage([]byte("hello"))
+	if err != nil {
+		t.Fatalf("SignMessage failed: %v", err)
+	}
+	if !bytes.Equal(sig, want) {
+		t.Errorf("expected signature %x, got %x", want, sig)
+	}
+
+	if ok, err := VerifyMessage([]byte("hello"), want, signer); err != nil || !ok {
+		t.Errorf("expected the signature to verify, got %v, %v", ok, err)
+	}
+	lowV := append([]byte(nil), want...)
+	lowV[64] -= 27
+	if ok, err := VerifyMessage([]byte("hello"), lowV, signer); err != nil || !ok {
+		t.Errorf("expected a V of 0 or 1 to verify, got %v, %v", ok, err)
+	}
+	if ok, err := VerifyMessage([]byte("hello!"), want, signer); err != nil || ok {
+		t.Errorf("expected a different message not to verify, got %v, %v", ok, err)
+	}
+	if ok, err := VerifyMessage([]byte("hello"), want, testContractAddress); err != nil || ok {
+		t.Errorf("expected another address not to verify, got %v, %v", ok, err)
+	}
+	if _, err := VerifyMessage([]byte("hello"), want[:64], signer); err == nil {
+		t.Error("expected an error for a truncated signature")
+	}
+}
+
+func TestForwardRequestDigestMatchesEIP712(t *testing.T) {
+	forwarder := common.HexToAddress("0x00000000000000000000000000000000000000fa")
+	req := forwardRequest{
+		From:  common.HexToAddress("0x00000000000000000000000000000000000000b1"),
+		To:    testContractAddress,
+		Value: big.NewInt(0),
+		Gas:   big.NewInt(150000),
+		Nonce: big.NewInt(2),
+		Data:  []byte{0xb6, 0xb5, 0x5f, 0x25, 0x01},
+	}
+
+	typedData := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"ForwardRequest": {
+				{Name: "from", Type: "address"},
+				{Name: "to", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "gas", Type: "uint256"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "data", Type: "bytes"},
+			},
+		},
+		PrimaryType: "ForwardRequest",
+		Domain: apitypes.TypedDataDomain{
+			Name:              "MinimalForwarder",
+			Version:           "0.0.1",
+			ChainId:           (*math.HexOrDecimal256)(big.NewInt(137)),
+			VerifyingContract: forwarder.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"from":  req.From.Hex(),
+			"to":    req.To.Hex(),
+			"value": req.Value.String(),
+			"gas":   req.Gas.String(),
+			"nonce": req.Nonce.String(),
+			"data":  "0x" + common.Bytes2Hex(req.Data),
+		},
+	}
+	want, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := forwardRequestDigest(big.NewInt(137), forwarder, req); !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("digest mismatch:\n got %x\nwant %x", got, want)
+	}
+}
+
+// recordingRelayer captures the meta-transactions it is asked to relay
+type recordingRelayer struct {
+	relayed [][]byte
+	hash    common.Hash
+}
+
+func (r *recordingRelayer) Relay(ctx context.Context, signedMetaTx []byte) (common.Hash, error) {
+	r.relayed = append(r.relayed, signedMetaTx)
+	return r.hash, nil
+}
+
+func TestDepositViaRelayerSignsForwardRequest(t *testing.T) {
+	forwarder := common.HexToAddress("0x00000000000000000000000000000000000000fa")
+	client, backend := newTestClient(t)
+	relayer := &recordingRelayer{hash: common.HexToHash("0xfeed")}
+	WithMetaTxRelayer(relayer, forwarder)(client)
+	if err := backend.SetCallResultAt(forwarder, forwarderABI.Methods["getNonce"], big.NewInt(3)); err != nil {
+		t.Fatal(err)
+	}
+
+	amount := big.NewInt(1000)
+	hash, err := client.DepositViaRelayer(context.Background(), amount)
+	if err != nil {
+		t.Fatalf("DepositViaRelayer failed: %v", err)
+	}
+	if hash != relayer.hash {
+		t.Errorf("expected the relayer's hash %s, got %s", relayer.hash.Hex(), hash.Hex())
+	}
+	if sent := backend.SentTransactions(); len(sent) != 0 {
+		t.Errorf("expected nothing broadcast directly, got %d transactions", len(sent))
+	}
+	if len(relayer.relayed) != 1 {
+		t.Fatalf("expected one relayed meta-transaction, got %d", len(relayer.relayed))
+	}
+
+	metaTx := relayer.relayed[0]
+	execute := forwarderABI.Methods["execute"]
+	if !bytes.Equal(metaTx[:4], execute.ID) {
+		t.Fatalf("expected an execute call, got selector %x", metaTx[:4])
+	}
+	args, err := execute.Inputs.Unpack(metaTx[4:])
+	if err != nil {
+		t.Fatalf("failed to unpack execute args: %v", err)
+	}
+	var req forwardRequest
+	if err := execute.Inputs.Copy(&[]interface{}{&req, new([]byte)}, args); err != nil {
+		t.Fatalf("failed to copy forward request: %v", err)
+	}
+	sig := append([]byte(nil), args[1].([]byte)...)
+
+	wantData, _ := client.contractABI.Pack("deposit", amount)
+	if req.From != client.from() || req.To != testContractAddress || req.Nonce.Int64() != 3 ||
+		req.Gas.Uint64() != backend.GasLimit || req.Value.Sign() != 0 || !bytes.Equal(req.Data, wantData) {
+		t.Errorf("unexpected forward request: %+v", req)
+	}
+
+	if v := sig[64]; v != 27 && v != 28 {
+		t.Fatalf("expected v of 27 or 28, got %d", v)
+	}
+	sig[64] -= 27
+	pub, err := crypto.SigToPub(forwardRequestDigest(big.NewInt(1), forwarder, req).Bytes(), sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != client.from() {
+		t.Errorf("forward request signed by %s, want %s", signer.Hex(), client.from().Hex())
+	}
+}
+
+func TestDepositViaRelayerRequiresRelayer(t *testing.T) {
+	client, _ := newTestClient(t)
+	if _, err := client.DepositViaRelayer(context.Background(), big.NewInt(1000)); !errors.Is(err, ErrNoRelayer) {
+		t.Errorf("expected ErrNoRelayer, got %v", err)
+	}
+}
+
+// mockPrivateSender records submitted bundles, mining a block without them each time
+// until includeAt bundles have been submitted. onSubmit, if set, runs before each one.
+type mockPrivateSender struct {
+	backend   *ethtest.Backend
+	includeAt int
+	onSubmit  func()
+	bundles   [][]*types.Transaction
+	blocks    []uint64
+}
+
+func (m *mockPrivateSender) SendBundle(ctx context.Context, signedTxs []*types.Transaction, blockNumber uint64) error {
+	if m.onSubmit != nil {
+		m.onSubmit()
+	}
+	m.bundles = append(m.bundles, signedTxs)
+	m.blocks = append(m.blocks, blockNumber)
+	if len(m.bundles) == m.includeAt {
+		m.backend.SetReceipt(signedTxs[0].Hash(), &types.Receipt{Status: types.ReceiptStatusSuccessful, BlockNumber: new(big.Int).SetUint64(blockNumber)})
+		return nil
+	}
+	m.backend.Head = &types.Header{Number: new(big.Int).SetUint64(blockNumber), Difficulty: big.NewInt(0)}
+	return nil
+}
+
+func TestDepositPrivateResubmitsUntilIncluded(t *testing.T) {
+	client, backend := newTestClient(t)
+	sender := &mockPrivateSender{backend: backend, includeAt: 2}
+	WithPrivateSender(sender, 0)(client)
+	WithReceiptPollInterval(time.Millisecond)(client)
+
+	hash, err := client.DepositPrivate(context.Background(), big.NewInt(1000))
+	if err != nil {
+		t.Fatalf("DepositPrivate failed: %v", err)
+	}
+	if len(sender.bundles) != 2 || sender.blocks[0] != 2 || sender.blocks[1] != 3 {
+		t.Fatalf("expected bundles for blocks 2 and 3, got %v", sender.blocks)
+	}
+	for _, bundle := range sender.bundles {
+		if len(bundle) != 1 || bundle[0].Hash() != hash {
+			t.Errorf("expected a bundle of the deposit %s, got %v", hash.Hex(), bundle)
+		}
+	}
+	tx := sender.bundles[0][0]
+	if tx.To() == nil || *tx.To() != testContractAddress || !bytes.Equal(tx.Data()[:4], client.contractABI.Methods["deposit"].ID) {
+		t.Errorf("expected a signed deposit to the pool, got %x to %v", tx.Data(), tx.To())
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected nothing sent to the public mempool, got %d", sent)
+	}
+}
+
+func TestDepositPrivateGivesUpAfterMaxBlocks(t *testing.T) {
+	client, backend := newTestClient(t)
+	sender := &mockPrivateSender{backend: backend}
+	WithPrivateSender(sender, 3)(client)
+	WithReceiptPollInterval(time.Millisecond)(client)
+
+	if _, err := client.DepositPrivate(context.Background(), big.NewInt(1000)); !errors.Is(err, ErrBundleNotIncluded) {
+		t.Fatalf("expected ErrBundleNotIncluded, got %v", err)
+	}
+	if len(sender.bundles) != 3 {
+		t.Errorf("expected 3 bundles, got %d", len(sender.bundles))
+	}
+}
+
+func TestDepositPrivateDoesNotBlockOtherSends(t *testing.T) {
+	client, backend := newTestClient(t)
+	sender := &mockPrivateSender{backend: backend, includeAt: 2}
+	WithPrivateSender(sender, 0)(client)
+	WithReceiptPollInterval(time.Millisecond)(client)
+
+	var public *types.Transaction
+	sender.onSubmit = func() {
+		if public != nil {
+			return
+		}
+		done := make(chan error, 1)
+		go func() {
+			var err error
+			public, err = client.Deposit(context.Background(), big.NewInt(500))
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Deposit during a pending bundle failed: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Deposit blocked while the bundle was pending")
+		}
+	}
+
+	if _, err := client.DepositPrivate(context.Background(), big.NewInt(1000)); err != nil {
+		t.Fatalf("DepositPrivate failed: %v", err)
+	}
+	private := sender.bundles[0][0]
+	if public == nil || public.Nonce() != private.Nonce()+1 {
+		t.Fatalf("expected the public deposit to take nonce %d, got %v", private.Nonce()+1, public)
+	}
+	if _, err := client.Deposit(context.Background(), big.NewInt(500)); err != nil {
+		t.Fatalf("Deposit after the bundle resolved failed: %v", err)
+	}
+	if len(client.privateNonces) != 0 {
+		t.Errorf("expected the private nonce reservation to be released, got %v", client.privateNonces)
+	}
+}
+
+func TestDepositPrivateRequiresSender(t *testing.T) {
+	client, _ := newTestClient(t)
+	if _, err := client.DepositPrivate(context.Background(), big.NewInt(1000)); !errors.Is(err, ErrNoPrivateSender) {
+		t.Errorf("expected ErrNoPrivateSender, got %v", err)
+	}
+}
+
+func TestFlashbotsSenderSignsBundleRequest(t *testing.T) {
+	authKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := types.NewTransaction(0, testContractAddress, big.NewInt(0), 21000, big.NewInt(1), nil)
+	signedTx, err := types.SignTx(tx, types.HomesteadSigner{}, authKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body []byte
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header.Get("X-Flashbots-Signature")
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":{"bundleHash":"0x01"}}`)
+	}))
+	defer server.Close()
+
+	if err := NewFlashbotsSender(server.URL, authKey).SendBundle(context.Background(), []*types.Transaction{signedTx}, 18); err != nil {
+		t.Fatalf("SendBundle failed: %v", err)
+	}
+
+	var req struct {
+		Method string `json:"method"`
+		Params []struct {
+			Txs         []string `json:"txs"`
+			BlockNumber string   `json:"blockNumber"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := signedTx.MarshalBinary()
+	if req.Method != "eth_sendBundle" || len(req.Params) != 1 || req.Params[0].BlockNumber != "0x12" ||
+		len(req.Params[0].Txs) != 1 || req.Params[0].Txs[0] != fmt.Sprintf("0x%x", raw) {
+		t.Fatalf("unexpected bundle request %s", body)
+	}
+
+	address, sigHex, ok := strings.Cut(header, ":")
+	if !ok || address != crypto.PubkeyToAddress(authKey.PublicKey).Hex() {
+		t.Fatalf("unexpected signature header %q", header)
+	}
+	sig := common.FromHex(sigHex)
+	digest := crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n66%s", crypto.Keccak256Hash(body).Hex())))
+	pub, err := crypto.SigToPub(digest, sig)
+	if err != nil || crypto.PubkeyToAddress(*pub).Hex() != address {
+		t.Errorf("signature does not recover to %s: %v", address, err)
+	}
+}
+
+func TestFlashbotsSenderReportsRelayError(t *testing.T) {
+	authKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"bundle too large"}}`)
+	}))
+	defer server.Close()
+
+	err = NewFlashbotsSender(server.URL, authKey).SendBundle(context.Background(), nil, 18)
+	if err == nil || !strings.Contains(err.Error(), "bundle too large") {
+		t.Errorf("expected the relay's error, got %v", err)
+	}
+}
+
+const depositWithPermitABI = `{"type":"function","name":"depositWithPermit","inputs":[{"name":"amount","type":"uint256"},{"name":"deadline","type":"uint256"},{"name":"v","type":"uint8"},{"name":"r","type":"bytes32"},{"name":"s","type":"bytes32"}],"outputs":[]}`
+
+func TestDepositWithPermit(t *testing.T) {
+	token := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	r, s := [32]byte{1}, [32]byte{2}
+
+	t.Run("pool accepts permit", func(t *testing.T) {
+		client, backend := newTestClient(t, depositWithPermitABI)
+		if _, err := client.DepositWithPermit(context.Background(), big.NewInt(100), big.NewInt(1700003600), 27, r, s); err != nil {
+			t.Fatalf("DepositWithPermit failed: %v", err)
+		}
+
+		sent := backend.SentTransactions()
+		if len(sent) != 1 || *sent[0].To() != testContractAddress {
+			t.Fatalf("expected one transaction to the pool, got %d", len(sent))
+		}
+		if method, err := client.contractABI.MethodById(sent[0].Data()); err != nil || method.Name != "depositWithPermit" {
+			t.Errorf("expected a depositWithPermit call, got %v (%v)", method, err)
+		}
+	})
+
+	t.Run("separate permit and deposit", func(t *testing.T) {
+		client, backend := newTestClient(t)
+		WithStakingToken(token)(client)
+		if err := backend.SetCallResult(erc20ABI.Methods["allowance"], big.NewInt(100)); err != nil {
+			t.Fatal(err)
+		}
+
+		tx, err := client.DepositWithPermit(context.Background(), big.NewInt(100), big.NewInt(1700003600), 27, r, s)
+		if err != nil {
+			t.Fatalf("DepositWithPermit failed: %v", err)
+		}
+
+		sent := backend.SentTransactions()
+		if len(sent) != 2 {
+			t.Fatalf("expected permit and deposit transactions, got %d", len(sent))
+		}
+		if *sent[0].To() != token || !bytes.Equal(sent[0].Data()[:4], permitABI.Methods["permit"].ID) {
+			t.Errorf("expected the first transaction to call permit on the token")
+		}
+		if *sent[1].To() != testContractAddress || tx.Hash() != sent[1].Hash() {
+			t.Errorf("expected the returned transaction to be the pool deposit")
+		}
+	})
+
+	t.Run("options apply to permit and deposit", func(t *testing.T) {
+		client, backend := newTestClient(t)
+		WithStakingToken(token)(client)
+		if err := backend.SetCallResult(erc20ABI.Methods["allowance"], big.NewInt(100)); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.DepositWithPermit(context.Background(), big.NewInt(100), big.NewInt(1700003600), 27, r, s, WithGasPrice(big.NewInt(42e9)), withNonce(5)); err != nil {
+			t.Fatalf("DepositWithPermit failed: %v", err)
+		}
+
+		sent := backend.SentTransactions()
+		if len(sent) != 2 || *sent[0].To() != token {
+			t.Fatalf("expected permit and deposit transactions, got %d", len(sent))
+		}
+		for i, tx := range sent {
+			if tx.GasPrice().Int64() != 42e9 {
+				t.Errorf("transaction %d: expected gas price 42 gwei, got %s", i, tx.GasPrice())
+			}
+			if tx.Nonce() != uint64(5+i) {
+				t.Errorf("transaction %d: expected nonce %d, got %d", i, 5+i, tx.Nonce())
+			}
+		}
+	})
+}
+
+func TestWaitForAcceptableGasWaitsForPriceDrop(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.GasPrice = big.NewInt(100e9)
+	ft := newFakeTicker()
+	client.newTicker = func(time.Duration) ticker { return ft }
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.WaitForAcceptableGas(context.Background(), big.NewInt(30e9), time.Second)
+	}()
+
+	// Still spiking at the next poll
+	ft.ch <- time.Now()
+	waitForMethodCalls(t, backend, "SuggestGasPrice", 2)
+	select {
+	case err := <-done:
+		t.Fatalf("returned while gas was high: %v", err)
+	default:
+	}
+
+	backend.SetGasPrice(big.NewInt(25e9))
+	ft.ch <- time.Now()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("WaitForAcceptableGas failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not return after gas dropped")
+	}
+	if polls := backend.MethodCalls("SuggestGasPrice"); polls != 3 {
+		t.Errorf("expected 3 gas price polls, got %d", polls)
+	}
+}
+
+// waitForMethodCalls blocks until backend has seen at least n calls to method
+func waitForMethodCalls(t *testing.T, backend *ethtest.Backend, method string, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for backend.MethodCalls(method) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d %s calls", n, method)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWaitForAcceptableGasReportsPriceOnTimeout(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.GasPrice = big.NewInt(100e9)
+	client.newTicker = func(time.Duration) ticker { return newFakeTicker() }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := client.WaitForAcceptableGas(ctx, big.NewInt(30e9), time.Second)
+	if !errors.Is(err, ErrGasPriceTooHigh) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected ErrGasPriceTooHigh and DeadlineExceeded, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "100000000000") {
+		t.Errorf("expected the current gas price in %q", err)
+	}
+}
+
+func TestWithContractOverridesSingleCall(t *testing.T) {
+	client, backend := newTestClient(t)
+	migrated := common.HexToAddress("0x00000000000000000000000000000000000000d1")
+	ctx := context.Background()
+
+	calls := []struct {
+		name string
+		send func(opts ...CallOption) (*types.Transaction, error)
+	}{
+		{"deposit", func(opts ...CallOption) (*types.Transaction, error) {
+			return client.Deposit(ctx, big.NewInt(1e18), opts...)
+		}},
+		{"withdraw", func(opts ...CallOption) (*types.Transaction, error) {
+			return client.Withdraw(ctx, big.NewInt(1e18), opts...)
+		}},
+		{"claimRewards", func(opts ...CallOption) (*types.Transaction, error) { return client.ClaimRewards(ctx, opts...) }},
+	}
+
+	for _, call := range calls {
+		t.Run(call.name, func(t *testing.T) {
+			overridden, err := call.send(WithContract(migrated))
+			if err != nil {
+				t.Fatalf("overridden call failed: %v", err)
+			}
+			if *overridden.To() != migrated {
+				t.Errorf("expected the override to target %s, got %s", migrated.Hex(), overridden.To().Hex())
+			}
+
+			regular, err := call.send()
+			if err != nil {
+				t.Fatalf("regular call failed: %v", err)
+			}
+			if *regular.To() != testContractAddress {
+				t.Errorf("expected later calls to target %s, got %s", testContractAddress.Hex(), regular.To().Hex())
+			}
+			if client.contractAddress != testContractAddress {
+				t.Errorf("expected the stored contract to be unchanged, got %s", client.contractAddress.Hex())
+			}
+		})
+	}
+
+	if sent := len(backend.SentTransactions()); sent != 6 {
+		t.Errorf("expected 6 sent transactions, got %d", sent)
+	}
+}
+
+const customErrorsABI = `{"type":"error","name":"DepositTooLarge","inputs":[{"name":"amount","type":"uint256"},{"name":"max","type":"uint256"}]},
+{"type":"error","name":"NotWhitelisted","inputs":[{"name":"","type":"address"}]}`
+
+// rpcRevertError mimics the JSON-RPC error go-ethereum returns for a reverted call
+type rpcRevertError struct {
+	data string
+}
+
+func (e *rpcRevertError) Error() string          { return "execution reverted" }
+func (e *rpcRevertError) ErrorCode() int         { return 3 }
+func (e *rpcRevertError) ErrorData() interface{} { return e.data }
+
+func newRevertError(t *testing.T, data []byte) error {
+	t.Helper()
+	return &rpcRevertError{data: "0x" + common.Bytes2Hex(data)}
+}
+
+func TestDepositDecodesCustomRevert(t *testing.T) {
+	client, backend := newTestClient(t, customErrorsABI)
+
+	abiErr := client.contractABI.Errors["DepositTooLarge"]
+	args, err := abiErr.Inputs.Pack(big.NewInt(5e18), big.NewInt(1e18))
+	if err != nil {
+		t.Fatalf("failed to pack error args: %v", err)
+	}
+	backend.SetEstimateGasError(newRevertError(t, append(abiErr.ID[:4:4], args...)))
+
+	_, err = client.Deposit(context.Background(), big.NewInt(5e18))
+	if !errors.Is(err, ErrExecutionReverted) {
+		t.Fatalf("expected ErrExecutionReverted, got %v", err)
+	}
+	var revert *CustomRevertError
+	if !errors.As(err, &revert) {
+		t.Fatalf("expected a CustomRevertError, got %T", err)
+	}
+	if revert.Name != "DepositTooLarge" {
+		t.Errorf("expected DepositTooLarge, got %s", revert.Name)
+	}
+	if amount, ok := revert.Args["amount"].(*big.Int); !ok || amount.Cmp(big.NewInt(5e18)) != 0 {
+		t.Errorf("expected amount 5e18, got %v", revert.Args["amount"])
+	}
+	if max, ok := revert.Args["max"].(*big.Int); !ok || max.Cmp(big.NewInt(1e18)) != 0 {
+		t.Errorf("expected max 1e18, got %v", revert.Args["max"])
+	}
+	if !strings.Contains(err.Error(), "DepositTooLarge(amount=5000000000000000000, max=1000000000000000000)") {
+		t.Errorf("expected the decoded error in the message, got %q", err.Error())
+	}
+}
+
+func TestSimulateWithdrawAsAnotherAccount(t *testing.T) {
+	client, backend := newTestClient(t)
+	borrower := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	setCallResult(t, client, backend, "withdraw")
+	setCallResult(t, client, backend, "claimRewards")
+
+	if err := client.SimulateWithdraw(context.Background(), big.NewInt(1e18), WithFrom(borrower)); err != nil {
+		t.Fatalf("SimulateWithdraw failed: %v", err)
+	}
+	if err := client.SimulateClaimRewards(context.Background()); err != nil {
+		t.Fatalf("SimulateClaimRewards failed: %v", err)
+	}
+
+	calls := backend.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(calls))
+	}
+	if calls[0].From != borrower {
+		t.Errorf("expected the withdrawal to be simulated from %s, got %s", borrower.Hex(), calls[0].From.Hex())
+	}
+	if !bytes.Equal(calls[0].Data[:4], client.contractABI.Methods["withdraw"].ID) || *calls[0].To != testContractAddress {
+		t.Errorf("expected a withdraw call to the pool")
+	}
+	if calls[1].From != client.from() {
+		t.Errorf("expected simulations to default to the signer, got %s", calls[1].From.Hex())
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected nothing sent, got %d transactions", sent)
+	}
+}
+
+func TestSimulateDepositReportsRevert(t *testing.T) {
+	client, backend := newTestClient(t, customErrorsABI)
+	account := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+
+	whitelist := client.contractABI.Errors["NotWhitelisted"]
+	args, err := whitelist.Inputs.Pack(account)
+	if err != nil {
+		t.Fatalf("failed to pack error args: %v", err)
+	}
+	name, _ := client.findOverload("deposit", 1)
+	backend.SetCallError(client.contractABI.Methods[name], newRevertError(t, append(whitelist.ID[:4:4], args...)))
+
+	err = client.SimulateDeposit(context.Background(), big.NewInt(1e18), WithFrom(account))
+	var revert *CustomRevertError
+	if !errors.As(err, &revert) || revert.Name != "NotWhitelisted" {
+		t.Fatalf("expected the NotWhitelisted revert, got %v", err)
+	}
+	if from := backend.Calls()[0].From; from != account {
+		t.Errorf("expected the deposit to be simulated from %s, got %s", account.Hex(), from.Hex())
+	}
+}
+
+func TestParseRevert(t *testing.T) {
+	client, _ := newTestClient(t, customErrorsABI)
+
+	whitelist := client.contractABI.Errors["NotWhitelisted"]
+	account := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	packed, err := whitelist.Inputs.Pack(account)
+	if err != nil {
+		t.Fatalf("failed to pack error args: %v", err)
+	}
+	t.Run("unnamed argument", func(t *testing.T) {
+		err := client.parseRevert(newRevertError(t, append(whitelist.ID[:4:4], packed...)))
+		var revert *CustomRevertError
+		if !errors.As(err, &revert) {
+			t.Fatalf("expected a CustomRevertError, got %v", err)
+		}
+		if got, ok := revert.Args["arg0"].(common.Address); !ok || got != account {
+			t.Errorf("expected arg0 %s, got %v", account.Hex(), revert.Args["arg0"])
+		}
+	})
+
+	t.Run("reason string", func(t *testing.T) {
+		reason, err := abi.Arguments{{Type: mustType(t, "string")}}.Pack("pool is full")
+		if err != nil {
+			t.Fatalf("failed to pack reason: %v", err)
+		}
+		data := append(crypto.Keccak256([]byte("Error(string)"))[:4], reason...)
+		err = client.parseRevert(newRevertError(t, data))
+		if !errors.Is(err, ErrExecutionReverted) || !strings.Contains(err.Error(), "pool is full") {
+			t.Errorf("expected the revert reason, got %v", err)
+		}
+		var revert *CustomRevertError
+		if errors.As(err, &revert) {
+			t.Errorf("expected a plain reason error, got %v", revert)
+		}
+	})
+
+	t.Run("unknown selector", func(t *testing.T) {
+		original := newRevertError(t, []byte{0xde, 0xad, 0xbe, 0xef})
+		if err := client.parseRevert(original); err != original {
+			t.Errorf("expected the original error, got %v", err)
+		}
+	})
+
+	t.Run("no revert data", func(t *testing.T) {
+		original := errors.New("connection refused")
+		if err := client.parseRevert(original); err != original {
+			t.Errorf("expected the original error, got %v", err)
+		}
+	})
+}
+
+func mustType(t *testing.T, name string) abi.Type {
+	t.Helper()
+	typ, err := abi.NewType(name, "", nil)
+	if err != nil {
+		t.Fatalf("failed to create %s type: %v", name, err)
+	}
+	return typ
+}
+
+// fakeClock advances only when the rate limiter sleeps, recording each delay
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return ctx.Err()
+}
+
+func TestRateLimitThrottlesCalls(t *testing.T) {
+	backend := ethtest.NewBackend()
+	client, err := NewYieldFarmingClientWithBackend(backend, testContractAddress, "", withRPCURL("http://localhost:8545"),
+		WithReconnect(0, 0), WithRateLimit(RateLimit{RPS: 4, Burst: 2}))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	limited, ok := client.client.(*rateLimitedBackend)
+	if !ok {
+		t.Fatalf("expected a rate-limited backend, got %T", client.client)
+	}
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	limited.limiter = newRateLimiter(RateLimit{RPS: 4, Burst: 2}, clock.Now, clock.Sleep)
+
+	for i := 0; i < 6; i++ {
+		if _, err := client.GetLatestBlock(context.Background()); err != nil {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+	}
+
+	// The burst of two goes through immediately; the rest are spaced at 4 per second
+	want := []time.Duration{250 * time.Millisecond, 250 * time.Millisecond, 250 * time.Millisecond, 250 * time.Millisecond}
+	if len(clock.sleeps) != len(want) {
+		t.Fatalf("expected %d waits, got %v", len(want), clock.sleeps)
+	}
+	for i, d := range want {
+		if clock.sleeps[i] != d {
+			t.Errorf("wait %d: expected %s, got %s", i, d, clock.sleeps[i])
+		}
+	}
+	if calls := backend.MethodCalls("BlockNumber"); calls != 6 {
+		t.Errorf("expected 6 BlockNumber calls, got %d", calls)
+	}
+}
+
+func TestRateLimiterRefillsOverTime(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	limiter := newRateLimiter(RateLimit{RPS: 10}, clock.Now, clock.Sleep)
+	ctx := context.Background()
+
+	if err := limiter.wait(ctx); err != nil {
+		t.Fatalf("first wait failed: %v", err)
+	}
+	clock.now = clock.now.Add(time.Second)
+	if err := limiter.wait(ctx); err != nil {
+		t.Fatalf("second wait failed: %v", err)
+	}
+	if len(clock.sleeps) != 0 {
+		t.Errorf("expected a refilled bucket not to wait, got %v", clock.sleeps)
+	}
+	if err := limiter.wait(ctx); err != nil {
+		t.Fatalf("third wait failed: %v", err)
+	}
+	if len(clock.sleeps) != 1 || clock.sleeps[0] != 100*time.Millisecond {
+		t.Errorf("expected one 100ms wait with a burst of one, got %v", clock.sleeps)
+	}
+}
+
+func TestRateLimiterRespectsContext(t *testing.T) {
+	limiter := newRateLimiter(RateLimit{RPS: 0.01, Burst: 1}, time.Now, sleepContext)
+	if err := limiter.wait(context.Background()); err != nil {
+		t.Fatalf("first wait failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := limiter.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the wait to end with the context, took %s", elapsed)
+	}
+	if limiter.tokens < -0.01 {
+		t.Errorf("expected the cancelled reservation to be returned, tokens=%f", limiter.tokens)
+	}
+}
+
+func TestRateLimitDisabledByDefault(t *testing.T) {
+	backend := ethtest.NewBackend()
+	client, err := NewYieldFarmingClientWithBackend(backend, testContractAddress, "")
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if _, ok := client.client.(*rateLimitedBackend); ok {
+		t.Error("expected no rate limiting without WithRateLimit")
+	}
+}
+
+const multiRewardABI = `{"type":"function","name":"earned","stateMutability":"view","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"earned","stateMutability":"view","inputs":[{"name":"account","type":"address"},{"name":"rewardsToken","type":"address"}],"outputs":[{"name":"","type":"uint256"}]}`
+
+func TestGetAllPendingRewards(t *testing.T) {
+	client, backend := newTestClient(t, multiRewardABI)
+	primary := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	secondary := common.HexToAddress("0x00000000000000000000000000000000000000a2")
+	WithRewardToken(primary)(client)
+	WithRewardTokens(secondary, primary)(client)
+
+	user := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	name, ok := client.findOverload("earned", 2)
+	if !ok {
+		t.Fatal("expected the two-argument earned overload")
+	}
+	earned := client.contractABI.Methods[name]
+	if err := backend.SetCallResultFor(earned, []interface{}{user, primary}, big.NewInt(3e18)); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.SetCallResultFor(earned, []interface{}{user, secondary}, big.NewInt(7e17)); err != nil {
+		t.Fatal(err)
+	}
+
+	rewards, err := client.GetAllPendingRewards(context.Background(), user)
+	if err != nil {
+		t.Fatalf("GetAllPendingRewards failed: %v", err)
+	}
+	if len(rewards) != 2 {
+		t.Fatalf("expected 2 reward tokens, got %v", rewards)
+	}
+	if rewards[primary].Cmp(big.NewInt(3e18)) != 0 {
+		t.Errorf("expected 3e18 primary rewards, got %s", rewards[primary])
+	}
+	if rewards[secondary].Cmp(big.NewInt(7e17)) != 0 {
+		t.Errorf("expected 7e17 secondary rewards, got %s", rewards[secondary])
+	}
+	if calls := len(backend.Calls()); calls != 2 {
+		t.Errorf("expected one call per distinct token, got %d", calls)
+	}
+}
+
+func TestGetAllPendingRewardsErrors(t *testing.T) {
+	user := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+
+	client, _ := newTestClient(t, multiRewardABI)
+	if _, err := client.GetAllPendingRewards(context.Background(), user); !errors.Is(err, ErrTokenNotConfigured) {
+		t.Errorf("expected ErrTokenNotConfigured without reward tokens, got %v", err)
+	}
+
+	client, _ = newTestClient(t)
+	WithRewardToken(common.HexToAddress("0x00000000000000000000000000000000000000a1"))(client)
+	if _, err := client.GetAllPendingRewards(context.Background(), user); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("expected ErrUnsupportedMethod without earned(address,address), got %v", err)
+	}
+}
+
+const claimToABI = `{"type":"function","name":"claimTo","stateMutability":"nonpayable","inputs":[{"name":"recipient","type":"address"}],"outputs":[]}`
+
+func TestClaimRewardsTo(t *testing.T) {
+	client, backend := newTestClient(t, claimToABI)
+	coldWallet := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+
+	tx, err := client.ClaimRewardsTo(context.Background(), coldWallet)
+	if err != nil {
+		t.Fatalf("ClaimRewardsTo failed: %v", err)
+	}
+	if *tx.To() != testContractAddress {
+		t.Errorf("expected the claim to go to the pool, got %s", tx.To().Hex())
+	}
+
+	method := client.contractABI.Methods["claimTo"]
+	if !bytes.Equal(tx.Data()[:4], method.ID) {
+		t.Fatalf("expected a claimTo call, got selector %x", tx.Data()[:4])
+	}
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		t.Fatalf("failed to unpack claimTo args: %v", err)
+	}
+	if got := args[0].(common.Address); got != coldWallet {
+		t.Errorf("expected recipient %s, got %s", coldWallet.Hex(), got.Hex())
+	}
+	if sent := len(backend.SentTransactions()); sent != 1 {
+		t.Errorf("expected 1 sent transaction, got %d", sent)
+	}
+}
+
+func TestClaimRewardsToUnsupported(t *testing.T) {
+	client, backend := newTestClient(t)
+
+	_, err := client.ClaimRewardsTo(context.Background(), common.HexToAddress("0x00000000000000000000000000000000000000c0"))
+	if !errors.Is(err, ErrClaimToUnsupported) {
+		t.Fatalf("expected ErrClaimToUnsupported, got %v", err)
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected nothing sent, got %d transactions", sent)
+	}
+}
+
+func TestClaimRewardsToRejectsZeroRecipient(t *testing.T) {
+	client, backend := newTestClient(t, claimToABI)
+
+	if _, err := client.ClaimRewardsTo(context.Background(), common.Address{}); err == nil {
+		t.Fatal("expected an error for the zero address")
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected nothing sent, got %d transactions", sent)
+	}
+}
+
+const withdrawalFeeABI = `{"type":"function","name":"withdrawalFee","stateMutability":"view","inputs":[{"name":"user","type":"address"}],"outputs":[{"name":"fee","type":"uint256"},{"name":"feeFreeAfter","type":"uint256"}]}`
+
+func TestGetWithdrawalFee(t *testing.T) {
+	client, backend := newTestClient(t, withdrawalFeeABI)
+	setCallResult(t, client, backend, "withdrawalFee", tokens(1, 17), big.NewInt(1700086400))
+
+	fee, feeFreeAfter, err := client.GetWithdrawalFee(context.Background(), client.from())
+	if err != nil {
+		t.Fatalf("GetWithdrawalFee failed: %v", err)
+	}
+	if fee.Cmp(tokens(1, 17)) != 0 || feeFreeAfter.Cmp(big.NewInt(1700086400)) != 0 {
+		t.Errorf("expected a 1e17 fee until 1700086400, got %s until %s", fee, feeFreeAfter)
+	}
+
+	client, _ = newTestClient(t)
+	fee, feeFreeAfter, err = client.GetWithdrawalFee(context.Background(), client.from())
+	if err != nil {
+		t.Fatalf("GetWithdrawalFee failed: %v", err)
+	}
+	if fee.Sign() != 0 || feeFreeAfter.Sign() != 0 {
+		t.Errorf("expected no fee without a fee view, got %s until %s", fee, feeFreeAfter)
+	}
+}
+
+func TestWithdrawAllReportsNetAmount(t *testing.T) {
+	// The mock position stakes 10 tokens
+	tests := []struct {
+		name    string
+		fee     *big.Int
+		wantNet *big.Int
+	}{
+		{name: "early withdrawal fee", fee: new(big.Int).Div(tokens(1, 18), big.NewInt(2)), wantNet: new(big.Int).Div(tokens(95, 18), big.NewInt(10))},
+		{name: "fee-free", fee: big.NewInt(0), wantNet: tokens(10, 18)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t, withdrawalFeeABI)
+			setCallResult(t, client, backend, "withdrawalFee", tt.fee, big.NewInt(1700086400))
+
+			tx, net, err := client.WithdrawAll(context.Background())
+			if err != nil {
+				t.Fatalf("WithdrawAll failed: %v", err)
+			}
+			if net.Cmp(tt.wantNet) != 0 {
+				t.Errorf("expected net %s, got %s", tt.wantNet, net)
+			}
+			args, err := client.contractABI.Methods["withdraw"].Inputs.Unpack(tx.Data()[4:])
+			if err != nil {
+				t.Fatalf("failed to unpack withdraw args: %v", err)
+			}
+			if got := args[0].(*big.Int); got.Cmp(tokens(10, 18)) != 0 {
+				t.Errorf("expected the full 10 token stake withdrawn, got %s", got)
+			}
+		})
+	}
+}
+
+func TestWithdrawalFeeTolerance(t *testing.T) {
+	// A 0.5 token fee on a 10 token stake is 500 bps
+	tests := []struct {
+		tolerance uint64
+		wantErr   error
+	}{
+		{tolerance: 100, wantErr: ErrWithdrawalFeeTooHigh},
+		{tolerance: 499, wantErr: ErrWithdrawalFeeTooHigh},
+		{tolerance: 500, wantErr: nil},
+	}
+
+	for _, tt := range tests {
+		client, backend := newTestClient(t, withdrawalFeeABI)
+		WithWithdrawalFeeTolerance(tt.tolerance)(client)
+		setCallResult(t, client, backend, "withdrawalFee", new(big.Int).Div(tokens(1, 18), big.NewInt(2)), big.NewInt(1700086400))
+
+		_, err := client.Withdraw(context.Background(), tokens(4, 18))
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("tolerance %d bps: expected %v, got %v", tt.tolerance, tt.wantErr, err)
+		}
+		if tt.wantErr != nil && !strings.Contains(err.Error(), "200000000000000000 of 4000000000000000000") {
+			t.Errorf("tolerance %d bps: expected the prorated fee in %q", tt.tolerance, err)
+		}
+	}
+}
+
+const partialClaimABI = `{"type":"function","name":"claim","stateMutability":"nonpayable","inputs":[{"name":"amount","type":"uint256"}],"outputs":[]}`
+
+func TestClaimRewardsAmount(t *testing.T) {
+	// The mock position has 0.5 reward tokens pending
+	client, backend := newTestClient(t, partialClaimABI)
+
+	tx, err := client.ClaimRewardsAmount(context.Background(), big.NewInt(2e17))
+	if err != nil {
+		t.Fatalf("ClaimRewardsAmount failed: %v", err)
+	}
+
+	method := client.contractABI.Methods["claim"]
+	if !bytes.Equal(tx.Data()[:4], method.ID) {
+		t.Fatalf("expected a claim(uint256) call, got selector %x", tx.Data()[:4])
+	}
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		t.Fatalf("failed to unpack claim args: %v", err)
+	}
+	if got := args[0].(*big.Int); got.Cmp(big.NewInt(2e17)) != 0 {
+		t.Errorf("expected amount 2e17, got %s", got)
+	}
+	if sent := len(backend.SentTransactions()); sent != 1 {
+		t.Errorf("expected 1 sent transaction, got %d", sent)
+	}
+}
+
+func TestClaimRewardsAmountRejectsOverClaim(t *testing.T) {
+	client, backend := newTestClient(t, partialClaimABI)
+
+	if _, err := client.ClaimRewardsAmount(context.Background(), big.NewInt(1e18)); !errors.Is(err, ErrExceedsPendingRewards) {
+		t.Fatalf("expected ErrExceedsPendingRewards, got %v", err)
+	}
+	if _, err := client.ClaimRewardsAmount(context.Background(), big.NewInt(0)); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("expected ErrInvalidAmount, got %v", err)
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected nothing sent, got %d transactions", sent)
+	}
+}
+
+func TestClaimRewardsAmountFallsBackToFullClaim(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	tx, err := client.ClaimRewardsAmount(context.Background(), big.NewInt(2e17))
+	if err != nil {
+		t.Fatalf("ClaimRewardsAmount failed: %v", err)
+	}
+	if id := client.contractABI.Methods["claimRewards"].ID; !bytes.Equal(tx.Data()[:4], id) {
+		t.Errorf("expected a full claimRewards call, got selector %x", tx.Data()[:4])
+	}
+}
+
+func TestFallbackGasLimit(t *testing.T) {
+	t.Run("estimate succeeds", func(t *testing.T) {
+		client, backend := newTestClient(t)
+		WithFallbackGasLimit(300000)(client)
+		backend.GasLimit = 65000
+
+		tx, err := client.Deposit(context.Background(), big.NewInt(1e18))
+		if err != nil {
+			t.Fatalf("Deposit failed: %v", err)
+		}
+		if tx.Gas() != 65000 {
+			t.Errorf("expected the estimated limit 65000, got %d", tx.Gas())
+		}
+	})
+
+	t.Run("estimate fails with fallback", func(t *testing.T) {
+		client, backend := newTestClient(t)
+		WithFallbackGasLimit(300000)(client)
+		backend.SetEstimateGasError(errors.New("execution reverted: insufficient allowance"))
+
+		tx, err := client.Deposit(context.Background(), big.NewInt(1e18))
+		if err != nil {
+			t.Fatalf("expected the send to proceed, got %v", err)
+		}
+		if tx.Gas() != 300000 {
+			t.Errorf("expected the fallback limit 300000, got %d", tx.Gas())
+		}
+		if sent := len(backend.SentTransactions()); sent != 1 {
+			t.Errorf("expected 1 sent transaction, got %d", sent)
+		}
+	})
+
+	t.Run("estimate fails without fallback", func(t *testing.T) {
+		client, backend := newTestClient(t)
+		backend.SetEstimateGasError(errors.New("execution reverted: insufficient allowance"))
+
+		if _, err := client.Deposit(context.Background(), big.NewInt(1e18)); err == nil {
+			t.Fatal("expected the estimate failure to abort the send")
+		}
+		if sent := len(backend.SentTransactions()); sent != 0 {
+			t.Errorf("expected nothing sent, got %d transactions", sent)
+		}
+	})
+}
+
+const pendingRewardsABI = `{"type":"function","name":"pendingRewards","stateMutability":"view","inputs":[{"name":"user","type":"address"}],"outputs":[{"name":"","type":"uint256"}]}`
+
+func TestGetPendingRewardsAt(t *testing.T) {
+	client, backend := newTestClient(t, pendingRewardsABI)
+	method := client.contractABI.Methods["pendingRewards"]
+	user := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+
+	if err := backend.SetCallResult(method, big.NewInt(999)); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.SetCallResultAtBlock(100, method, big.NewInt(1e17)); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.SetCallResultAtBlock(200, method, big.NewInt(4e17)); err != nil {
+		t.Fatal(err)
+	}
+
+	for block, want := range map[int64]*big.Int{100: big.NewInt(1e17), 200: big.NewInt(4e17)} {
+		got, err := client.GetPendingRewardsAt(context.Background(), user, big.NewInt(block))
+		if err != nil {
+			t.Fatalf("GetPendingRewardsAt(%d) failed: %v", block, err)
+		}
+		if got.Cmp(want) != 0 {
+			t.Errorf("block %d: expected %s, got %s", block, want, got)
+		}
+	}
+}
+
+func TestGetPendingRewardsAtPrunedState(t *testing.T) {
+	client, backend := newTestClient(t, pendingRewardsABI)
+	method := client.contractABI.Methods["pendingRewards"]
+	user := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+
+	backend.SetCallError(method, errors.New("missing trie node 9a3b... (path ) state 0x9a3b is not available"))
+	_, err := client.GetPendingRewardsAt(context.Background(), user, big.NewInt(100))
+	if !errors.Is(err, ErrArchiveNotSupported) {
+		t.Fatalf("expected ErrArchiveNotSupported, got %v", err)
+	}
+
+	backend.SetCallError(method, errors.New("execution timeout"))
+	_, err = client.GetPendingRewardsAt(context.Background(), user, big.NewInt(100))
+	if err == nil || errors.Is(err, ErrArchiveNotSupported) {
+		t.Errorf("expected other failures to pass through, got %v", err)
+	}
+}
+
+func TestGetPendingRewardsAtValidation(t *testing.T) {
+	user := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+
+	client, _ := newTestClient(t, pendingRewardsABI)
+	if _, err := client.GetPendingRewardsAt(context.Background(), user, nil); err == nil {
+		t.Error("expected an error for a nil block number")
+	}
+
+	client, _ = newTestClient(t)
+	if _, err := client.GetPendingRewardsAt(context.Background(), user, big.NewInt(1)); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("expected ErrUnsupportedMethod, got %v", err)
+	}
+}
+
+// historicalPrices is a HistoricalPriceProvider with a price table per block and no
+// current prices
+type historicalPrices map[uint64]fixedPrices
+
+func (p historicalPrices) PriceUSD(ctx context.Context, token common.Address) (*big.Float, error) {
+	return nil, errors.New("no current price")
+}
+
+func (p historicalPrices) PriceUSDAt(ctx context.Context, token common.Address, blockNumber *big.Int) (*big.Float, error) {
+	return p[blockNumber.Uint64()].PriceUSD(ctx, token)
+}
+
+func TestGetAPYAt(t *testing.T) {
+	stakingToken := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	rewardToken := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	client, backend := newTestClient(t, emissionScheduleABI)
+	WithStakingToken(stakingToken)(client)
+	WithRewardToken(rewardToken)(client)
+	WithStakingTokenDecimals(18)(client)
+	WithRewardTokenDecimals(18)(client)
+
+	// 1e15 per second is 31536 tokens a year
+	for block, tvl := range map[uint64]*big.Int{100: tokens(1000, 18), 200: tokens(2000, 18)} {
+		if err := backend.SetCallResultAtBlock(block, erc20ABI.Methods["balanceOf"], tvl); err != nil {
+			t.Fatal(err)
+		}
+		if err := backend.SetCallResultAtBlock(block, client.contractABI.Methods["rewardRate"], big.NewInt(1e15)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for block, want := range map[int64]float64{100: 3153.6, 200: 1576.8} {
+		apy, err := client.GetAPYAt(context.Background(), big.NewInt(block))
+		if err != nil {
+			t.Fatalf("GetAPYAt(%d) failed: %v", block, err)
+		}
+		assertFloat(t, fmt.Sprintf("APY at block %d", block), apy, want)
+	}
+
+	// Staking tokens worth twice the reward token halve the APY
+	WithPriceProvider(historicalPrices{100: {stakingToken: 2, rewardToken: 1}})(client)
+	apy, err := client.GetAPYAt(context.Background(), big.NewInt(100))
+	if err != nil {
+		t.Fatalf("GetAPYAt with prices failed: %v", err)
+	}
+	assertFloat(t, "priced APY", apy, 1576.8)
+
+	for _, call := range backend.Calls() {
+		if call.To == nil || *call.To != stakingToken && *call.To != testContractAddress {
+			t.Errorf("unexpected call to %v", call.To)
+		}
+	}
+}
+
+func TestGetAPYAtPrunedState(t *testing.T) {
+	client, backend := newTestClient(t, emissionScheduleABI)
+	WithStakingToken(common.HexToAddress("0x00000000000000000000000000000000000000aa"))(client)
+
+	backend.SetCallError(erc20ABI.Methods["balanceOf"], errors.New("missing trie node 9a3b... (path )"))
+	if _, err := client.GetAPYAt(context.Background(), big.NewInt(100)); !errors.Is(err, ErrArchiveNotSupported) {
+		t.Fatalf("expected ErrArchiveNotSupported, got %v", err)
+	}
+}
+
+const depositForABI = `{"type":"function","name":"depositFor","stateMutability":"nonpayable","inputs":[{"name":"beneficiary","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[]}`
+
+func TestDepositFor(t *testing.T) {
+	client, backend := newTestClient(t, depositForABI)
+	user := common.HexToAddress("0x00000000000000000000000000000000000000d1")
+
+	tx, err := client.DepositFor(context.Background(), user, big.NewInt(2e18))
+	if err != nil {
+		t.Fatalf("DepositFor failed: %v", err)
+	}
+
+	method := client.contractABI.Methods["depositFor"]
+	if !bytes.Equal(tx.Data()[:4], method.ID) {
+		t.Fatalf("expected a depositFor call, got selector %x", tx.Data()[:4])
+	}
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		t.Fatalf("failed to unpack depositFor args: %v", err)
+	}
+	if got := args[0].(common.Address); got != user {
+		t.Errorf("expected beneficiary %s, got %s", user.Hex(), got.Hex())
+	}
+	if got := args[1].(*big.Int); got.Cmp(big.NewInt(2e18)) != 0 {
+		t.Errorf("expected amount 2e18, got %s", got)
+	}
+	if sent := len(backend.SentTransactions()); sent != 1 {
+		t.Errorf("expected 1 sent transaction, got %d", sent)
+	}
+}
+
+func TestDepositForRejections(t *testing.T) {
+	user := common.HexToAddress("0x00000000000000000000000000000000000000d1")
+
+	client, backend := newTestClient(t, depositForABI)
+	if _, err := client.DepositFor(context.Background(), common.Address{}, big.NewInt(1e18)); err == nil {
+		t.Error("expected an error for the zero beneficiary")
+	}
+	if _, err := client.DepositFor(context.Background(), user, big.NewInt(0)); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("expected ErrInvalidAmount, got %v", err)
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected nothing sent, got %d transactions", sent)
+	}
+
+	client, _ = newTestClient(t)
+	if _, err := client.DepositFor(context.Background(), user, big.NewInt(1e18)); !errors.Is(err, ErrDepositForUnsupported) {
+		t.Errorf("expected ErrDepositForUnsupported, got %v", err)
+	}
+}
+
+const depositReferralABI = `{"type":"function","name":"deposit","stateMutability":"nonpayable","inputs":[{"name":"amount","type":"uint256"},{"name":"referrer","type":"address"}],"outputs":[]}`
+
+func TestDepositWithReferral(t *testing.T) {
+	client, backend := newTestClient(t, depositReferralABI)
+	referrer := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+
+	tx, err := client.DepositWithReferral(context.Background(), big.NewInt(1e18), referrer)
+	if err != nil {
+		t.Fatalf("DepositWithReferral failed: %v", err)
+	}
+	method, ok := client.findReferralDeposit()
+	if !ok {
+		t.Fatal("expected the referral deposit overload")
+	}
+	if !bytes.Equal(tx.Data()[:4], client.contractABI.Methods[method].ID) {
+		t.Fatalf("expected a deposit(uint256,address) call, got selector %x", tx.Data()[:4])
+	}
+	args, err := client.contractABI.Methods[method].Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		t.Fatalf("failed to unpack deposit args: %v", err)
+	}
+	if args[0].(*big.Int).Cmp(big.NewInt(1e18)) != 0 || args[1].(common.Address) != referrer {
+		t.Errorf("expected 1e18 referred by %s, got %v", referrer.Hex(), args)
+	}
+	if sent := len(backend.SentTransactions()); sent != 1 {
+		t.Errorf("expected 1 sent transaction, got %d", sent)
+	}
+}
+
+func TestDepositWithReferralRejections(t *testing.T) {
+	referrer := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+
+	client, backend := newTestClient(t, depositReferralABI)
+	if _, err := client.DepositWithReferral(context.Background(), big.NewInt(1e18), client.from()); err == nil || !strings.Contains(err.Error(), "cannot refer yourself") {
+		t.Errorf("expected self-referral to be rejected, got %v", err)
+	}
+	if _, err := client.DepositWithReferral(context.Background(), big.NewInt(1e18), common.Address{}); err == nil {
+		t.Error("expected an error for the zero referrer")
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected nothing sent, got %d transactions", sent)
+	}
+
+	client, _ = newTestClient(t)
+	if _, err := client.DepositWithReferral(context.Background(), big.NewInt(1e18), referrer); !errors.Is(err, ErrReferralUnsupported) {
+		t.Errorf("expected ErrReferralUnsupported, got %v", err)
+	}
+}
+
+const depositDeadlineABI = `{"type":"function","name":"deposit","stateMutability":"nonpayable","inputs":[{"name":"amount","type":"uint256"},{"name":"deadline","type":"uint256"}],"outputs":[]}`
+
+func TestDepositWithDeadline(t *testing.T) {
+	client, backend := newTestClient(t, depositDeadlineABI)
+	backend.Head.Time = 1700000000
+
+	tx, err := client.DepositWithDeadline(context.Background(), big.NewInt(1e18), big.NewInt(1700000300))
+	if err != nil {
+		t.Fatalf("DepositWithDeadline failed: %v", err)
+	}
+
+	name, _ := client.findDeadlineDeposit()
+	method := client.contractABI.Methods[name]
+	if !bytes.Equal(tx.Data()[:4], method.ID) {
+		t.Fatalf("expected the deadline deposit overload, got selector %x", tx.Data()[:4])
+	}
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		t.Fatalf("failed to unpack deposit args: %v", err)
+	}
+	if got := args[0].(*big.Int); got.Cmp(big.NewInt(1e18)) != 0 {
+		t.Errorf("expected amount 1e18, got %s", got)
+	}
+	if got := args[1].(*big.Int); got.Cmp(big.NewInt(1700000300)) != 0 {
+		t.Errorf("expected deadline 1700000300, got %s", got)
+	}
+	if sent := len(backend.SentTransactions()); sent != 1 {
+		t.Errorf("expected 1 sent transaction, got %d", sent)
+	}
+}
+
+func TestDepositWithDeadlineRejectsExpired(t *testing.T) {
+	client, backend := newTestClient(t, depositDeadlineABI)
+	backend.Head.Time = 1700000000
+
+	for _, deadline := range []int64{1699999999, 1700000000} {
+		_, err := client.DepositWithDeadline(context.Background(), big.NewInt(1e18), big.NewInt(deadline))
+		if !errors.Is(err, ErrDeadlineExpired) {
+			t.Errorf("deadline %d: expected ErrDeadlineExpired, got %v", deadline, err)
+		}
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected nothing sent, got %d transactions", sent)
+	}
+
+	client, _ = newTestClient(t)
+	if _, err := client.DepositWithDeadline(context.Background(), big.NewInt(1e18), big.NewInt(1700000300)); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("expected ErrUnsupportedMethod without a deadline overload, got %v", err)
+	}
+}
+
+func TestSubmitReturnsSendResult(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.AutoMine = true
+	backend.SetNonce(client.from(), 11)
+	backend.SetGasPrice(big.NewInt(3e9))
+
+	result, err := client.SubmitDeposit(context.Background(), big.NewInt(1e18))
+	if err != nil {
+		t.Fatalf("SubmitDeposit failed: %v", err)
+	}
+	if result.Nonce != 11 {
+		t.Errorf("expected nonce 11, got %d", result.Nonce)
+	}
+	if result.GasPrice.Cmp(big.NewInt(3e9)) != 0 {
+		t.Errorf("expected gas price 3 gwei, got %s", result.GasPrice)
+	}
+	if sent := backend.SentTransactions(); len(sent) != 1 || sent[0].Hash() != result.Tx.Hash() {
+		t.Fatalf("expected the result to hold the sent transaction")
+	}
+
+	receipt, err := result.Wait(context.Background())
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if receipt.TxHash != result.Tx.Hash() {
+		t.Errorf("expected the receipt for %s, got %s", result.Tx.Hash().Hex(), receipt.TxHash.Hex())
+	}
+
+	claim, err := client.SubmitClaimRewards(context.Background())
+	if err != nil {
+		t.Fatalf("SubmitClaimRewards failed: %v", err)
+	}
+	if claim.Nonce != 12 {
+		t.Errorf("expected the next nonce 12, got %d", claim.Nonce)
+	}
+}
+
+func TestSendResultEffectiveGasPriceForDynamicFee(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.AutoMine = true
+	backend.Head.BaseFee = big.NewInt(4e8)
+	store := NewMemoryTxStore()
+	WithTxStore(store)(client)
+
+	result, err := client.SubmitDeposit(context.Background(), big.NewInt(1e18))
+	if err != nil {
+		t.Fatalf("SubmitDeposit failed: %v", err)
+	}
+	if result.Tx.Type() != types.DynamicFeeTxType {
+		t.Fatalf("expected a dynamic-fee transaction, got type %d", result.Tx.Type())
+	}
+	if result.GasPrice != nil {
+		t.Errorf("expected no gas price before the transaction is mined, got %s", result.GasPrice)
+	}
+	if result.GasFeeCap.Int64() != 14e8 || result.GasTipCap.Int64() != 6e8 {
+		t.Errorf("expected fee cap 14e8 and tip cap 6e8, got %s and %s", result.GasFeeCap, result.GasTipCap)
+	}
+
+	if _, err := result.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	// The 0.4 gwei base fee plus the full 0.6 gwei tip, not the 1.4 gwei cap
+	if result.GasPrice == nil || result.GasPrice.Int64() != 1e9 {
+		t.Errorf("expected an effective gas price of 1 gwei, got %v", result.GasPrice)
+	}
+	records, err := store.List(TxFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].GasPrice == nil || records[0].GasPrice.Int64() != 1e9 || records[0].GasFeeCap.Int64() != 14e8 {
+		t.Errorf("expected the record to hold the effective gas price and fee cap, got %+v", records)
+	}
+}
+
+// receiveResult waits for a queued operation's result
+func receiveResult(t *testing.T, results <-chan SendResult) SendResult {
+	t.Helper()
+
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a queued result")
+		return SendResult{}
+	}
+}
+
+func TestTxQueueSendsInOrderWithSequentialNonces(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.SetNonce(client.from(), 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := client.NewTxQueue(ctx)
+
+	results := []<-chan SendResult{
+		queue.Enqueue(DepositOp(big.NewInt(1e18))),
+		queue.Enqueue(WithdrawOp(big.NewInt(1e18))),
+		queue.Enqueue(ClaimRewardsOp()),
+	}
+
+	methods := []string{"deposit", "withdraw", "claimRewards"}
+	for i, ch := range results {
+		result := receiveResult(t, ch)
+		if result.Err != nil {
+			t.Fatalf("operation %d failed: %v", i, result.Err)
+		}
+		if result.Nonce != uint64(5+i) {
+			t.Errorf("operation %d: expected nonce %d, got %d", i, 5+i, result.Nonce)
+		}
+		if id := client.contractABI.Methods[methods[i]].ID; !bytes.Equal(result.Tx.Data()[:4], id) {
+			t.Errorf("operation %d: expected a %s call", i, methods[i])
+		}
+	}
+
+	sent := backend.SentTransactions()
+	if len(sent) != 3 {
+		t.Fatalf("expected 3 sent transactions, got %d", len(sent))
+	}
+	for i, tx := range sent {
+		if tx.Nonce() != uint64(5+i) {
+			t.Errorf("transaction %d broadcast with nonce %d, want %d", i, tx.Nonce(), 5+i)
+		}
+	}
+}
+
+func TestTxQueueAssignsNoncesLocally(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.SetNonce(client.from(), 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := client.NewTxQueue(ctx)
+
+	if result := receiveResult(t, queue.Enqueue(ClaimRewardsOp())); result.Err != nil || result.Nonce != 3 {
+		t.Fatalf("expected the first claim at nonce 3, got %+v", result)
+	}
+
+	// A lagging node must not make the queue reuse a nonce, and failed operations don't
+	// consume one
+	backend.SetNonce(client.from(), 0)
+	if result := receiveResult(t, queue.Enqueue(DepositOp(big.NewInt(0)))); !errors.Is(result.Err, ErrZeroAmount) {
+		t.Fatalf("expected ErrZeroAmount, got %v", result.Err)
+	}
+	if result := receiveResult(t, queue.Enqueue(ClaimRewardsOp())); result.Err != nil || result.Nonce != 4 {
+		t.Fatalf("expected the next claim at nonce 4, got %+v", result)
+	}
+}
+
+func TestTxQueueStopsOnCancel(t *testing.T) {
+	client, backend := newTestClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queue := client.NewTxQueue(ctx)
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		result := receiveResult(t, queue.Enqueue(ClaimRewardsOp()))
+		if errors.Is(result.Err, ErrQueueClosed) {
+			break
+		}
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Fatalf("expected the cancellation error, got %+v", result)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("queue did not close after cancellation")
+		}
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected nothing sent after cancellation, got %d transactions", sent)
+	}
+}
+
+func TestSubmitPassesErrorsThrough(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.SetEstimateGasError(errors.New("boom"))
+
+	result, err := client.SubmitWithdraw(context.Background(), big.NewInt(1e18))
+	if err == nil || result != nil {
+		t.Fatalf("expected an error and no result, got %v, %v", result, err)
+	}
+}
+
+func TestEstimateTransactionCostL1(t *testing.T) {
+	client, backend := newTestClient(t)
+	backend.GasLimit = 100000
+	backend.SetGasPrice(big.NewInt(2e9))
+
+	cost, err := client.EstimateTransactionCost(context.Background(), "deposit", big.NewInt(1e18))
+	if err != nil {
+		t.Fatalf("EstimateTransactionCost failed: %v", err)
+	}
+	if want := big.NewInt(2e14); cost.Cmp(want) != 0 {
+		t.Errorf("expected %s wei, got %s", want, cost)
+	}
+	if calls := len(backend.Calls()); calls != 0 {
+		t.Errorf("expected no fee oracle calls on L1, got %d", calls)
+	}
+}
+
+func TestEstimateTransactionCostOPStack(t *testing.T) {
+	client, backend := newTestClient(t)
+	WithChainKind(ChainKindOPStack)(client)
+	WithPriceProvider(fixedPrices{NativeToken: 2000})(client)
+	backend.GasLimit = 100000
+	backend.SetGasPrice(big.NewInt(1e6))
+	if err := backend.SetCallResultAt(opGasPriceOracle, opGasPriceOracleABI.Methods["getL1Fee"], big.NewInt(5e12)); err != nil {
+		t.Fatal(err)
+	}
+
+	cost, err := client.EstimateTransactionCost(context.Background(), "deposit", big.NewInt(1e18))
+	if err != nil {
+		t.Fatalf("EstimateTransactionCost failed: %v", err)
+	}
+	// 100000 gas * 1e6 wei + 5e12 wei L1 fee
+	if want := big.NewInt(5e12 + 1e11); cost.Cmp(want) != 0 {
+		t.Errorf("expected %s wei, got %s", want, cost)
+	}
+
+	calls := backend.Calls()
+	if len(calls) != 1 || *calls[0].To != opGasPriceOracle {
+		t.Fatalf("expected one call to the gas price oracle, got %d", len(calls))
+	}
+	args, err := opGasPriceOracleABI.Methods["getL1Fee"].Inputs.Unpack(calls[0].Data[4:])
+	if err != nil {
+		t.Fatalf("failed to unpack getL1Fee args: %v", err)
+	}
+	var tx types.Transaction
+	if err := tx.UnmarshalBinary(args[0].([]byte)); err != nil {
+		t.Fatalf("expected the oracle to receive a serialized transaction: %v", err)
+	}
+	if *tx.To() != testContractAddress {
+		t.Errorf("expected the serialized transaction to target the pool, got %s", tx.To().Hex())
+	}
+
+	usd, err := client.EstimateTransactionCostUSD(context.Background(), "deposit", big.NewInt(1e18))
+	if err != nil {
+		t.Fatalf("EstimateTransactionCostUSD failed: %v", err)
+	}
+	assertFloat(t, "cost USD", usd, 5.1e-6*2000)
+}
+
+func TestEstimateTransactionCostArbitrum(t *testing.T) {
+	client, backend := newTestClient(t)
+	WithChainKind(ChainKindArbitrum)(client)
+	backend.GasLimit = 600000
+	backend.SetGasPrice(big.NewInt(1e8))
+	method := arbNodeInterfaceABI.Methods["gasEstimateL1Component"]
+	if err := backend.SetCallResultAt(arbNodeInterface, method, uint64(450000), big.NewInt(2e8), big.NewInt(3e10)); err != nil {
+		t.Fatal(err)
+	}
+
+	cost, err := client.EstimateTransactionCost(context.Background(), "deposit", big.NewInt(1e18))
+	if err != nil {
+		t.Fatalf("EstimateTransactionCost failed: %v", err)
+	}
+	// 150000 execution gas * 1e8 + 450000 L1 gas * 2e8
+	if want := big.NewInt(150000*1e8 + 450000*2e8); cost.Cmp(want) != 0 {
+		t.Errorf("expected %s wei, got %s", want, cost)
+	}
+
+	WithGasLimits(map[string]uint64{"deposit": 200000})(client)
+	cost, err = client.EstimateTransactionCost(context.Background(), "deposit", big.NewInt(1e18))
+	if err != nil {
+		t.Fatalf("EstimateTransactionCost failed: %v", err)
+	}
+	// A configured limit is execution gas only, so the L1 gas is added on top
+	if want := big.NewInt(200000*1e8 + 450000*2e8); cost.Cmp(want) != 0 {
+		t.Errorf("expected %s wei with a configured limit, got %s", want, cost)
+	}
+}
+
+func TestEstimateTransactionCostOracleFailure(t *testing.T) {
+	client, backend := newTestClient(t)
+	WithChainKind(ChainKindOPStack)(client)
+	backend.SetCallError(opGasPriceOracleABI.Methods["getL1Fee"], errors.New("oracle unavailable"))
+
+	if _, err := client.EstimateTransactionCost(context.Background(), "deposit", big.NewInt(1e18)); err == nil {
+		t.Fatal("expected the oracle failure to be returned")
+	}
+}
+
+func TestWaitForTransactionReturnsRevertedReceipt(t *testing.T) {
+	client, backend := newTestClient(t)
+	tx, err := client.ClaimRewards(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := &types.Receipt{
+		Status:      types.ReceiptStatusFailed,
+		TxHash:      tx.Hash(),
+		GasUsed:     43210,
+		BlockNumber: big.NewInt(7),
+		Logs:        []*types.Log{{Address: testContractAddress}},
+	}
+	backend.SetReceipt(tx.Hash(), failed)
+
+	receipt, err := client.WaitForTransaction(context.Background(), tx)
+	if !errors.Is(err, ErrTransactionReverted) {
+		t.Fatalf("expected ErrTransactionReverted, got %v", err)
+	}
+	if receipt == nil {
+		t.Fatal("expected the failed receipt alongside the error")
+	}
+	if receipt.GasUsed != 43210 || len(receipt.Logs) != 1 {
+		t.Errorf("expected the receipt's gas and logs, got %d gas and %d logs", receipt.GasUsed, len(receipt.Logs))
+	}
+}
+
+const tokenViewsABI = `{"type":"function","name":"stakingToken","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+{"type":"function","name":"rewardsToken","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]}`
+
+func TestGetPoolTokens(t *testing.T) {
+	client, backend := newTestClient(t, tokenViewsABI)
+	staking := common.HexToAddress("0x00000000000000000000000000000000000005a1")
+	reward := common.HexToAddress("0x00000000000000000000000000000000000005b1")
+	setCallResult(t, client, backend, "stakingToken", staking)
+	setCallResult(t, client, backend, "rewardsToken", reward)
+
+	for i := 0; i < 2; i++ {
+		got, err := client.GetStakingToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetStakingToken failed: %v", err)
+		}
+		if got != staking {
+			t.Errorf("expected staking token %s, got %s", staking.Hex(), got.Hex())
+		}
+		got, err = client.GetRewardToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetRewardToken failed: %v", err)
+		}
+		if got != reward {
+			t.Errorf("expected reward token %s, got %s", reward.Hex(), got.Hex())
+		}
+	}
+	if calls := len(backend.Calls()); calls != 2 {
+		t.Errorf("expected the token addresses to be read once each, got %d calls", calls)
+	}
+}
+
+func TestGetPoolTokensPrefersConfigured(t *testing.T) {
+	client, backend := newTestClient(t, tokenViewsABI)
+	configured := common.HexToAddress("0x00000000000000000000000000000000000005c1")
+	WithStakingToken(configured)(client)
+
+	got, err := client.GetStakingToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetStakingToken failed: %v", err)
+	}
+	if got != configured {
+		t.Errorf("expected the configured token %s, got %s", configured.Hex(), got.Hex())
+	}
+	if calls := len(backend.Calls()); calls != 0 {
+		t.Errorf("expected no contract calls, got %d", calls)
+	}
+
+	client, _ = newTestClient(t)
+	if _, err := client.GetRewardToken(context.Background()); !errors.Is(err, ErrTokenNotConfigured) {
+		t.Errorf("expected ErrTokenNotConfigured without a view, got %v", err)
+	}
+}
+
+func TestDepositChecksAllowanceOfDiscoveredToken(t *testing.T) {
+	client, backend := newTestClient(t, tokenViewsABI)
+	staking := common.HexToAddress("0x00000000000000000000000000000000000005a1")
+	setCallResult(t, client, backend, "stakingToken", staking)
+	if err := backend.SetCallResultAt(staking, erc20ABI.Methods["allowance"], big.NewInt(1e17)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.Deposit(context.Background(), big.NewInt(1e18))
+	var allowanceErr *AllowanceError
+	if !errors.As(err, &allowanceErr) {
+		t.Fatalf("expected an AllowanceError from the discovered staking token, got %v", err)
+	}
+	if sent := len(backend.SentTransactions()); sent != 0 {
+		t.Errorf("expected nothing sent, got %d transactions", sent)
+	}
+}
+
+const emissionScheduleABI = `{"type":"function","name":"rewardRate","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"periodFinish","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]}`
+
+func TestEmissionScheduleAPY(t *testing.T) {
+	const start = 1700000000
+	tests := []struct {
+		name     string
+		rate     *big.Int
+		end      int64
+		head     uint64
+		wantRate *big.Int
+		wantAPY  float64
+	}{
+		// The pool holds 1000 staked tokens
+		{"pre-halving", big.NewInt(1e18), start + 86400, start, big.NewInt(1e18), 3153600},
+		{"post-halving", big.NewInt(5e17), start + 86400, start + 3600, big.NewInt(5e17), 1576800},
+		{"ended", big.NewInt(5e17), start + 86400, start + 86400, big.NewInt(0), 0},
+		{"no end", big.NewInt(25e16), 0, start, big.NewInt(25e16), 788400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t, emissionScheduleABI)
+			WithStakingTokenDecimals(18)(client)
+			WithRewardTokenDecimals(18)(client)
+			setPoolTVL(t, client, backend, tokens(1000, 18))
+			setCallResult(t, client, backend, "rewardRate", tt.rate)
+			setCallResult(t, client, backend, "periodFinish", big.NewInt(tt.end))
+			backend.Head.Time = tt.head
+
+			rate, err := client.GetCurrentEmissionRate(context.Background())
+			if err != nil {
+				t.Fatalf("GetCurrentEmissionRate failed: %v", err)
+			}
+			if rate.Cmp(tt.wantRate) != 0 {
+				t.Errorf("expected rate %s, got %s", tt.wantRate, rate)
+			}
+
+			apy, err := client.CalculateAPY(context.Background())
+			if err != nil {
+				t.Fatalf("CalculateAPY failed: %v", err)
+			}
+			assertFloat(t, "APY", apy, tt.wantAPY)
+		})
+	}
+}
+
+func TestGetNextRewardTime(t *testing.T) {
+	for _, tt := range []struct {
+		outputType string
+		value      interface{}
+	}{
+		{"uint256", big.NewInt(1700086400)},
+		{"uint64", uint64(1700086400)},
+		{"uint32", uint32(1700086400)},
+	} {
+		client, backend := newTestClient(t, fmt.Sprintf(`{"type":"function","name":"nextDistributionTime","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":%q}]}`, tt.outputType))
+		setCallResult(t, client, backend, "nextDistributionTime", tt.value)
+
+		next, err := client.GetNextRewardTime(context.Background())
+		if err != nil {
+			t.Fatalf("%s: GetNextRewardTime failed: %v", tt.outputType, err)
+		}
+		if !next.Equal(time.Unix(1700086400, 0)) {
+			t.Errorf("%s: expected 1700086400, got %s", tt.outputType, next)
+		}
+	}
+
+	client, _ := newTestClient(t, emissionScheduleABI)
+	if _, err := client.GetNextRewardTime(context.Background()); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported for a continuous-emission pool, got %v", err)
+	}
+}
+
+func TestGetEmissionScheduleEnd(t *testing.T) {
+	client, backend := newTestClient(t, emissionScheduleABI)
+	setCallResult(t, client, backend, "periodFinish", big.NewInt(1700086400))
+
+	end, err := client.GetEmissionScheduleEnd(context.Background())
+	if err != nil {
+		t.Fatalf("GetEmissionScheduleEnd failed: %v", err)
+	}
+	if !end.Equal(time.Unix(1700086400, 0)) {
+		t.Errorf("expected 1700086400, got %s", end)
+	}
+
+	client, _ = newTestClient(t)
+	if _, err := client.GetEmissionScheduleEnd(context.Background()); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("expected ErrUnsupportedMethod, got %v", err)
+	}
+	if _, err := client.GetCurrentEmissionRate(context.Background()); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("expected ErrUnsupportedMethod, got %v", err)
+	}
+}
+
+const poolEndABI = `{"type":"function","name":"endTime","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]}`
+
+func TestPoolEndTime(t *testing.T) {
+	const end = 1700086400
+
+	tests := []struct {
+		name      string
+		blockTime uint64
+		expired   bool
+	}{
+		{name: "active", blockTime: end - 1, expired: false},
+		{name: "ended at the latest block", blockTime: end, expired: true},
+		{name: "expired", blockTime: end + 3600, expired: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, backend := newTestClient(t, poolEndABI)
+			WithStakingTokenDecimals(18)(client)
+			WithRewardTokenDecimals(18)(client)
+			setPoolTVL(t, client, backend, tokens(1000, 18))
+			setCallResult(t, client, backend, "endTime", big.NewInt(end))
+			backend.Head.Time = tt.blockTime
+			ctx := context.Background()
+
+			endTime, err := client.GetPoolEndTime(ctx)
+			if err != nil {
+				t.Fatalf("GetPoolEndTime failed: %v", err)
+			}
+			if !endTime.Equal(time.Unix(end, 0)) {
+				t.Errorf("expected end %d, got %s", end, endTime)
+			}
+
+			info, err := client.GetPoolInfo(ctx)
+			if err != nil {
+				t.Fatalf("GetPoolInfo failed: %v", err)
+			}
+			if info.PoolExpired != tt.expired {
+				t.Errorf("expected PoolExpired %v, got %v", tt.expired, info.PoolExpired)
+			}
+
+			apy, err := client.CalculateAPY(ctx)
+			if err != nil {
+				t.Fatalf("CalculateAPY failed: %v", err)
+			}
+			if zero := apy.Sign() == 0; zero != tt.expired {
+				t.Errorf("expected a zero APY only once expired, got %s", apy.Text('f', 2))
+			}
+		})
+	}
+}
+
+func TestDepositIntoExpiredPool(t *testing.T) {
+	client, backend := newTestClient(t, poolEndABI)
+	setCallResult(t, client, backend, "endTime", big.NewInt(1700086400))
+	backend.Head.Time = 1700090000
+
+	// The check is opt-in
+	if _, err := client.Deposit(context.Background(), big.NewInt(1e18)); err != nil {
+		t.Fatalf("expected the deposit to proceed without the expiry check, got %v", err)
+	}
+
+	WithExpiredPoolCheck()(client)
+	if _, err := client.Deposit(context.Background(), big.NewInt(1e18)); !errors.Is(err, ErrPoolExpired) {
+		t.Fatalf("expected ErrPoolExpired, got %v", err)
+	}
+	if sent := len(backend.SentTransactions()); sent != 1 {
+		t.Errorf("expected only the unchecked deposit to be sent, got %d transactions", sent)
+	}
+
+	backend.Head.Time = 1700000000
+	if _, err := client.Deposit(context.Background(), big.NewInt(1e18)); err != nil {
+		t.Errorf("expected a deposit into an active pool to proceed, got %v", err)
+	}
+}
+
+func TestPreflightDeposit(t *testing.T) {
+	stakingToken := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	amount := big.NewInt(1e18)
+
+	type state struct {
+		balance, allowance, min, max *big.Int
+		paused                       bool
+	}
+	healthy := func() state {
+		return state{balance: big.NewInt(9e18), allowance: big.NewInt(9e18), min: big.NewInt(1e17), max: big.NewInt(5e18)}
+	}
+	tests := []struct {
+		name   string
+		modify func(*state)
+		check  func(t *testing.T, r *PreflightResult)
+	}{
+		{"all clear", func(*state) {}, func(t *testing.T, r *PreflightResult) {
+			if !r.OK() {
+				t.Errorf("expected no blockers, got %+v", r)
+			}
+		}},
+		{"insufficient balance", func(s *state) { s.balance = big.NewInt(5e17) }, func(t *testing.T, r *PreflightResult) {
+			if r.SufficientBalance || !r.SufficientAllowance || r.Paused || !r.WithinLimits {
+				t.Errorf("expected only the balance to block, got %+v", r)
+			}
+			if r.Balance.Cmp(big.NewInt(5e17)) != 0 {
+				t.Errorf("expected balance 5e17, got %s", r.Balance)
+			}
+		}},
+		{"insufficient allowance", func(s *state) { s.allowance = big.NewInt(4e17) }, func(t *testing.T, r *PreflightResult) {
+			if !r.SufficientBalance || r.SufficientAllowance || r.Paused || !r.WithinLimits {
+				t.Errorf("expected only the allowance to block, got %+v", r)
+			}
+			if r.AllowanceShortfall.Cmp(big.NewInt(6e17)) != 0 {
+				t.Errorf("expected shortfall 6e17, got %s", r.AllowanceShortfall)
+			}
+		}},
+		{"paused", func(s *state) { s.paused = true }, func(t *testing.T, r *PreflightResult) {
+			if !r.SufficientBalance || !r.SufficientAllowance || !r.Paused || !r.WithinLimits {
+				t.Errorf("expected only the pause to block, got %+v", r)
+			}
+		}},
+		{"below minimum", func(s *state) { s.min = big.NewInt(2e18) }, func(t *testing.T, r *PreflightResult) {
+			if !r.SufficientBalance || !r.SufficientAllowance || r.Paused || r.WithinLimits {
+				t.Errorf("expected only the limits to block, got %+v", r)
+			}
+			if r.MinDeposit.Cmp(big.NewInt(2e18)) != 0 {
+				t.Errorf("expected min deposit 2e18, got %s", r.MinDeposit)
+			}
+		}},
+		{"above maximum", func(s *state) { s.max = big.NewInt(5e17) }, func(t *testing.T, r *PreflightResult) {
+			if r.WithinLimits || r.OK() {
+				t.Errorf("expected the limits to block, got %+v", r)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := healthy()
+			tt.modify(&s)
+
+			client, backend := newTestClient(t, depositLimitsABI, pausableABI)
+			WithStakingToken(stakingToken)(client)
+			setCallResult(t, client, backend, "minDeposit", s.min)
+			setCallResult(t, client, backend, "maxDeposit", s.max)
+			setCallResult(t, client, backend, "paused", s.paused)
+			if err := backend.SetCallResultAt(stakingToken, erc20ABI.Methods["balanceOf"], s.balance); err != nil {
+				t.Fatal(err)
+			}
+			if err := backend.SetCallResultAt(stakingToken, erc20ABI.Methods["allowance"], s.allowance); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := client.PreflightDeposit(context.Background(), amount)
+			if err != nil {
+				t.Fatalf("PreflightDeposit failed: %v", err)
+			}
+			tt.check(t, result)
+			if sent := len(backend.SentTransactions()); sent != 0 {
+				t.Errorf("expected nothing sent, got %d transactions", sent)
+			}
+		})
+	}
+}
+
+func TestPreflightDepositRequiresStakingToken(t *testing.T) {
+	client, _ := newTestClient(t)
+	if _, err := client.PreflightDeposit(context.Background(), big.NewInt(1e18)); !errors.Is(err, ErrTokenNotConfigured) {
+		t.Errorf("expected ErrTokenNotConfigured, got %v", err)
+	}
+}
+
+func TestGasEstimateBlockState(t *testing.T) {
+	client, backend := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := client.Deposit(ctx, big.NewInt(1e18)); err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	if _, err := client.Deposit(ctx, big.NewInt(1e18), WithEstimateAt(rpc.LatestBlockNumber)); err != nil {
+		t.Fatalf("Deposit at latest failed: %v", err)
+	}
+	if _, err := client.ClaimRewards(ctx, WithEstimateAt(rpc.BlockNumber(1234))); err != nil {
+		t.Fatalf("ClaimRewards at block failed: %v", err)
+	}
+
+	want := []rpc.BlockNumber{rpc.PendingBlockNumber, rpc.LatestBlockNumber, rpc.BlockNumber(1234)}
+	got := backend.EstimateBlocks()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d estimates, got %v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("estimate %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestEthRPCBackendSendsBlockTag(t *testing.T) {
+	var params []json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if req.Method != "eth_estimateGas" {
+			t.Errorf("expected eth_estimateGas, got %s", req.Method)
+		}
+		params = req.Params
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0xc350"}`, req.ID)
+	}))
+	defer server.Close()
+
+	backend, err := dialEthRPCBackend(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer backend.Close()
+
+	gas, err := backend.EstimateGasAtBlock(context.Background(), ethereum.CallMsg{To: &testContractAddress, Data: []byte{1, 2, 3, 4}}, rpc.PendingBlockNumber)
+	if err != nil {
+		t.Fatalf("EstimateGasAtBlock failed: %v", err)
+	}
+	if gas != 50000 {
+		t.Errorf("expected 50000 gas, got %d", gas)
+	}
+	if len(params) != 2 || string(params[1]) != `"pending"` {
+		t.Errorf("expected the pending block tag as the second param, got %s", params)
+	}
+	if !strings.Contains(string(params[0]), `"input":"0x01020304"`) {
+		t.Errorf("expected the call data in the call object, got %s", params[0])
+	}
+}
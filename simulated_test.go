@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"blockchain-yield-farming/ethtest"
+)
+
+// transactOptsSigner signs with a bind.TransactOpts, so keyed transactors from the
+// simulated backend's examples can drive the client
+type transactOptsSigner struct {
+	auth *bind.TransactOpts
+}
+
+func (s transactOptsSigner) Address() common.Address {
+	return s.auth.From
+}
+
+func (s transactOptsSigner) SignTx(tx *types.Transaction, _ types.Signer) (*types.Transaction, error) {
+	return s.auth.Signer(s.auth.From, tx)
+}
+
+// NewSimulatedClient starts a simulated chain funded by alloc, deploys the ethtest test
+// pool from auth's account and returns a client sending from auth to that pool. Every
+// transaction is mined as soon as it is sent.
+func NewSimulatedClient(auth *bind.TransactOpts, alloc core.GenesisAlloc, opts ...ClientOption) (*YieldFarmingClient, *ethtest.Simulated, error) {
+	sim := ethtest.NewSimulated(alloc)
+	pool, err := sim.DeployTestPool(context.Background(), auth)
+	if err != nil {
+		sim.Close()
+		return nil, nil, err
+	}
+
+	opts = append([]ClientOption{WithChainID(big.NewInt(ethtest.SimulatedChainID))}, opts...)
+	client, err := NewYieldFarmingClientWithBackend(sim, pool, "", opts...)
+	if err != nil {
+		sim.Close()
+		return nil, nil, err
+	}
+	client.contractABI, err = abi.JSON(strings.NewReader(ethtest.TestPoolABI))
+	if err != nil {
+		sim.Close()
+		return nil, nil, fmt.Errorf("failed to parse test pool ABI: %w", err)
+	}
+	client.SetSigner(transactOptsSigner{auth: auth})
+	return client, sim, nil
+}
+
+func newSimulatedTestClient(t *testing.T) (*YieldFarmingClient, *bind.TransactOpts) {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(ethtest.SimulatedChainID))
+	if err != nil {
+		t.Fatalf("failed to create transactor: %v", err)
+	}
+	alloc := core.GenesisAlloc{auth.From: {Balance: new(big.Int).Mul(big.NewInt(100), big.NewInt(1e18))}}
+
+	client, sim, err := NewSimulatedClient(auth, alloc, WithReceiptPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create simulated client: %v", err)
+	}
+	t.Cleanup(func() { sim.Close() })
+	return client, auth
+}
+
+func TestSimulatedDepositLifecycle(t *testing.T) {
+	client, auth := newSimulatedTestClient(t)
+	ctx := context.Background()
+
+	deposit, err := client.Deposit(ctx, big.NewInt(5e18))
+	if err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	if _, err := client.WaitForTransaction(ctx, deposit); err != nil {
+		t.Fatalf("deposit did not confirm: %v", err)
+	}
+
+	position, err := client.GetUserPosition(ctx, auth.From)
+	if err != nil {
+		t.Fatalf("GetUserPosition failed: %v", err)
+	}
+	if position.StakedBalance.Cmp(big.NewInt(5e18)) != 0 {
+		t.Errorf("expected 5e18 staked, got %s", position.StakedBalance)
+	}
+	if position.PendingRewards.Cmp(big.NewInt(5e16)) != 0 {
+		t.Errorf("expected 5e16 pending rewards, got %s", position.PendingRewards)
+	}
+
+	withdraw, err := client.Withdraw(ctx, big.NewInt(2e18))
+	if err != nil {
+		t.Fatalf("Withdraw failed: %v", err)
+	}
+	if _, err := client.WaitForTransaction(ctx, withdraw); err != nil {
+		t.Fatalf("withdrawal did not confirm: %v", err)
+	}
+
+	position, err = client.GetUserPosition(ctx, auth.From)
+	if err != nil {
+		t.Fatalf("GetUserPosition failed: %v", err)
+	}
+	if position.StakedBalance.Cmp(big.NewInt(3e18)) != 0 {
+		t.Errorf("expected 3e18 staked after withdrawing, got %s", position.StakedBalance)
+	}
+
+	head, err := client.GetLatestBlock(ctx)
+	if err != nil {
+		t.Fatalf("GetLatestBlock failed: %v", err)
+	}
+	flows, err := client.ComputeNetFlows(ctx, auth.From, 0, head)
+	if err != nil {
+		t.Fatalf("ComputeNetFlows failed: %v", err)
+	}
+	if flows.Deposited.Cmp(big.NewInt(5e18)) != 0 || flows.Withdrawn.Cmp(big.NewInt(2e18)) != 0 {
+		t.Errorf("expected 5e18 deposited and 2e18 withdrawn, got %s and %s", flows.Deposited, flows.Withdrawn)
+	}
+}
+
+func TestSimulatedWithdrawAboveStakeFails(t *testing.T) {
+	client, _ := newSimulatedTestClient(t)
+	ctx := context.Background()
+
+	deposit, err := client.Deposit(ctx, big.NewInt(1e18))
+	if err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	if _, err := client.WaitForTransaction(ctx, deposit); err != nil {
+		t.Fatalf("deposit did not confirm: %v", err)
+	}
+
+	if _, err := client.Withdraw(ctx, big.NewInt(2e18)); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("expected ErrInsufficientBalance from the on-chain stake, got %v", err)
+	}
+}